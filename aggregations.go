@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// aggDataset is the aggregation suite's collection of -agg-docs documents
+// from indexDocTemplate, without secondary indexes.
+var aggDataset = dataset{collection: collectionSeeded, docs: aggregationDocs}
+
+// spillSortMemoryBytes is the sort memory limit AggSortSpill runs with, far
+// below what sorting -agg-docs documents needs, so every iteration spills.
+const spillSortMemoryBytes = 100 << 10
+
+// restoreTimeout bounds putting back a server parameter a scenario changed,
+// which has to happen even when the run was interrupted.
+const restoreTimeout = 10 * time.Second
+
+// aggVariants returns a variants function making one scenario per
+// -allow-disk-use and -agg-decode setting from base, which provides the
+// dataset and any setup or teardown. pipeline builds the pipeline of
+// iteration i.
+func aggVariants(name string, base scenario, pipeline func(env *runEnv, i int) mongo.Pipeline) func(cfg *runConfig) []scenario {
+	return func(cfg *runConfig) []scenario {
+		var variants []scenario
+		for _, allowDiskUse := range cfg.allowDiskUse {
+			for _, decode := range cfg.aggDecode {
+				sc := base
+				sc.name = name
+				sc.params = map[string]string{
+					"allowDiskUse": strconv.FormatBool(allowDiskUse),
+					"decode":       strconv.FormatBool(decode),
+				}
+				sc.op = func(ctx context.Context, env *runEnv, i int) error {
+					return aggregate(ctx, env.aggColl(), pipeline(env, i), allowDiskUse, decode)
+				}
+				sc.explain = func(env *runEnv) (*mongo.Collection, bson.D) {
+					return env.aggColl(), aggregateCommand(env.aggColl(), pipeline(env, 0), allowDiskUse)
+				}
+				variants = append(variants, sc)
+			}
+		}
+		return variants
+	}
+}
+
+const sortMemoryParam = "internalQueryMaxBlockingSortMemoryUsageBytes"
+
+// sortMemory reads the server's blocking sort memory limit.
+func sortMemory(ctx context.Context, env *runEnv) (int64, error) {
+	cmd := bson.D{{Key: "getParameter", Value: 1}, {Key: sortMemoryParam, Value: 1}}
+	raw, err := env.client.Database("admin").RunCommand(ctx, cmd).Raw()
+	if err != nil {
+		return 0, err
+	}
+	bytes, ok := raw.Lookup(sortMemoryParam).AsInt64OK()
+	if !ok {
+		return 0, fmt.Errorf("getParameter: no numeric %s", sortMemoryParam)
+	}
+	return bytes, nil
+}
+
+// setSortMemory sets the server's blocking sort memory limit.
+func setSortMemory(ctx context.Context, env *runEnv, bytes int64) error {
+	cmd := bson.D{{Key: "setParameter", Value: 1}, {Key: sortMemoryParam, Value: bytes}}
+	return env.client.Database("admin").RunCommand(ctx, cmd).Err()
+}
+
+// sortSpillBase is AggSortSpill's setup and teardown: setup lowers the sort
+// memory limit and teardown puts back the value setup found, also after
+// the run's context was cancelled. The value is saved per target client,
+// since interleaved runs on several targets are open at once, and a
+// teardown whose setup never saved one leaves the server alone.
+//
+// A mongos has no such parameter and setting it there wouldn't reach the
+// shards, so the scenario only runs on standalones and replica sets.
+func sortSpillBase() scenario {
+	saved := map[*mongo.Client]int64{}
+	return scenario{
+		dataset:    aggDataset,
+		topologies: []string{topologyStandalone, topologyReplicaSet},
+		setup: func(ctx context.Context, env *runEnv) error {
+			bytes, err := sortMemory(ctx, env)
+			if err != nil {
+				return err
+			}
+			saved[env.client] = bytes
+			return setSortMemory(ctx, env, spillSortMemoryBytes)
+		},
+		teardown: func(ctx context.Context, env *runEnv) error {
+			bytes, ok := saved[env.client]
+			if !ok {
+				return nil
+			}
+			delete(saved, env.client)
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+			defer cancel()
+			return setSortMemory(ctx, env, bytes)
+		},
+	}
+}
+
+// The aggregation suite. Pipelines read the whole collection so their cost
+// grows with -agg-docs; the ones that take a value from i vary it so plan
+// caches see more than one shape of input.
+var (
+	// aggMatchGroupVariants filters and groups with accumulators.
+	aggMatchGroupVariants = aggVariants("AggMatchGroup", scenario{concurrent: true, dataset: aggDataset}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"attrs.size": bson.M{"$lte": 50 + i%50}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$attrs.color"},
+				{Key: "n", Value: bson.M{"$sum": 1}},
+				{Key: "avgCount", Value: bson.M{"$avg": "$count"}},
+				{Key: "lastEdit", Value: bson.M{"$max": "$editDate"}},
+			}}},
+		}
+	})
+
+	// aggLookupVariants joins one category's documents with the category
+	// collection.
+	aggLookupVariants = aggVariants("AggLookup", scenario{
+		concurrent: true,
+		dataset:    dataset{collection: collectionSeeded, docs: aggregationDocs, categories: true},
+	}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"category": pick(indexCategories, i)}}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: env.categoryColl().Name()},
+				{Key: "localField", Value: "category"},
+				{Key: "foreignField", Value: "_id"},
+				{Key: "as", Value: "categoryInfo"},
+			}}},
+		}
+	})
+
+	// aggUnwindVariants unwinds the tags array and counts documents per tag.
+	aggUnwindVariants = aggVariants("AggUnwind", scenario{concurrent: true, dataset: aggDataset}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$unwind", Value: "$tags"}},
+			{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "n", Value: bson.M{"$sum": 1}}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "n", Value: -1}}}},
+		}
+	})
+
+	// aggSortSpillVariants sorts the whole collection with the sort memory
+	// limit lowered so the sort spills to disk. Without allowDiskUse the
+	// server refuses, which the results record as errors. It changes a
+	// server parameter, so it can't share the server with other workers,
+	// and needs a standalone or replica set to change it on.
+	aggSortSpillVariants = aggVariants("AggSortSpill", sortSpillBase(), func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$sort", Value: bson.D{{Key: "description", Value: 1}, {Key: "count", Value: -1}}}},
+		}
+	})
+
+	// aggFacetVariants computes several summaries in one pass with $facet.
+	aggFacetVariants = aggVariants("AggFacet", scenario{concurrent: true, dataset: aggDataset}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$facet", Value: bson.D{
+				{Key: "byCategory", Value: bson.A{bson.M{"$sortByCount": "$category"}}},
+				{Key: "byColor", Value: bson.A{bson.M{"$sortByCount": "$attrs.color"}}},
+				{Key: "sizes", Value: bson.A{bson.M{"$bucketAuto": bson.M{"groupBy": "$attrs.size", "buckets": 5}}}},
+				{Key: "top", Value: bson.A{bson.M{"$sort": bson.M{"count": -1}}, bson.M{"$limit": 10}}},
+			}}},
+		}
+	})
+
+	// aggBucketVariants groups documents into fixed size ranges.
+	aggBucketVariants = aggVariants("AggBucket", scenario{concurrent: true, dataset: aggDataset}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$bucket", Value: bson.D{
+				{Key: "groupBy", Value: "$attrs.size"},
+				{Key: "boundaries", Value: bson.A{1, 25, 50, 75, 101}},
+				{Key: "default", Value: "other"},
+				{Key: "output", Value: bson.D{
+					{Key: "n", Value: bson.M{"$sum": 1}},
+					{Key: "avgCount", Value: bson.M{"$avg": "$count"}},
+				}},
+			}}},
+		}
+	})
+
+	// aggWindowVariants computes a running total and a rank per category
+	// with $setWindowFields, which needs 5.0 or later.
+	aggWindowVariants = aggVariants("AggWindow", scenario{concurrent: true, dataset: aggDataset}, func(env *runEnv, i int) mongo.Pipeline {
+		return mongo.Pipeline{
+			{{Key: "$setWindowFields", Value: bson.D{
+				{Key: "partitionBy", Value: "$category"},
+				{Key: "sortBy", Value: bson.D{{Key: "count", Value: 1}}},
+				{Key: "output", Value: bson.D{
+					{Key: "runningSize", Value: bson.D{
+						{Key: "$sum", Value: "$attrs.size"},
+						{Key: "window", Value: bson.M{"documents": bson.A{"unbounded", "current"}}},
+					}},
+					{Key: "rank", Value: bson.M{"$rank": bson.M{}}},
+				}},
+			}}},
+		}
+	})
+)
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// benchKey identifies the samples of one scenario on one server version.
+type benchKey struct {
+	scenario string
+	version  string
+}
+
+// sampleSet collects ns/op samples, and whatever latency and environment
+// details the files carry, from any mix of result files.
+type sampleSet struct {
+	scenarios []string
+	versions  []string
+	samples   map[benchKey][]float64
+	latencies map[benchKey][]latencySummary
+	// plans holds the first query plan seen for each scenario and version.
+	plans map[benchKey]*queryPlan
+	// serverMetrics holds each run's server metrics, per op where they are
+	// counters.
+	serverMetrics map[benchKey][]map[string]float64
+	meta          map[string]*versionMeta
+}
+
+// versionMeta is the environment one version's samples were measured in.
+type versionMeta struct {
+	targets []string
+	servers []serverInfo
+	hosts   []hostInfo
+	runIDs  []string
+	// header holds go test's goos/goarch/pkg/cpu lines for text logs.
+	header map[string]string
+}
+
+func newSampleSet() *sampleSet {
+	return &sampleSet{
+		samples:       map[benchKey][]float64{},
+		latencies:     map[benchKey][]latencySummary{},
+		plans:         map[benchKey]*queryPlan{},
+		serverMetrics: map[benchKey][]map[string]float64{},
+		meta:          map[string]*versionMeta{},
+	}
+}
+
+func (s *sampleSet) versionMeta(version string) *versionMeta {
+	m, ok := s.meta[version]
+	if !ok {
+		m = &versionMeta{header: map[string]string{}}
+		s.meta[version] = m
+	}
+	return m
+}
+
+// addResult adds a run command result. Runs that did no iterations, or
+// failed without a timing, aren't samples and are left out.
+func (s *sampleSet) addResult(res result) {
+	if res.Iterations == 0 || (res.Errors > 0 && res.NsPerOp == 0) {
+		return
+	}
+	version := res.versionLabel()
+	k := benchKey{res.variant(), version}
+	s.add(k.scenario, version, res.NsPerOp)
+	if res.Latency != nil {
+		s.latencies[k] = append(s.latencies[k], *res.Latency)
+	}
+	if res.Plan != nil && s.plans[k] == nil {
+		s.plans[k] = res.Plan
+	}
+	if res.ServerMetrics != nil && res.Iterations > 0 {
+		s.serverMetrics[k] = append(s.serverMetrics[k], res.ServerMetrics.perOp(res.Iterations))
+	}
+
+	m := s.versionMeta(version)
+	if !slices.Contains(m.targets, res.Target) {
+		m.targets = append(m.targets, res.Target)
+	}
+	if res.RunID != "" && !slices.Contains(m.runIDs, res.RunID) {
+		m.runIDs = append(m.runIDs, res.RunID)
+	}
+	if res.Server != nil && !slices.ContainsFunc(m.servers, func(s serverInfo) bool { return reflect.DeepEqual(s, *res.Server) }) {
+		m.servers = append(m.servers, *res.Server)
+	}
+	if res.Host != nil && !slices.Contains(m.hosts, *res.Host) {
+		m.hosts = append(m.hosts, *res.Host)
+	}
+}
+
+func (s *sampleSet) add(scenario, version string, nsPerOp float64) {
+	if !slices.Contains(s.scenarios, scenario) {
+		s.scenarios = append(s.scenarios, scenario)
+	}
+	if !slices.Contains(s.versions, version) {
+		s.versions = append(s.versions, version)
+	}
+	k := benchKey{scenario, version}
+	s.samples[k] = append(s.samples[k], nsPerOp)
+}
+
+// loadFile adds the samples in path, which is either run command JSON Lines
+// output or a saved go test -bench log like result50.txt.
+func (s *sampleSet) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		results, err := readResults(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, res := range results {
+			s.addResult(res)
+		}
+		return nil
+	}
+
+	var versions []string
+	err = parseGoTestOutput(bytes.NewReader(data), func(scenario, version string, nsPerOp float64) {
+		s.add(scenario, version, nsPerOp)
+		if !slices.Contains(versions, version) {
+			versions = append(versions, version)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	header := parseGoTestHeader(bytes.NewReader(data))
+	for _, v := range versions {
+		for k, val := range header {
+			s.versionMeta(v).header[k] = val
+		}
+	}
+	return nil
+}
+
+// parseGoTestHeader returns the goos/goarch/pkg/cpu lines of a go test log.
+func parseGoTestHeader(r io.Reader) map[string]string {
+	header := map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		switch key {
+		case "goos", "goarch", "pkg", "cpu":
+			if ok {
+				header[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return header
+}
+
+var (
+	benchLineRe   = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([0-9.eE+]+) ns/op`)
+	legacySuiteRe = regexp.MustCompile(`^BenchmarkMongo(\d)(\d)$`)
+	dupSuffixRe   = regexp.MustCompile(`#\d+$`)
+)
+
+// parseGoTestOutput reads go test -bench output. Both the old per-version
+// suites (BenchmarkMongo50/Scenario:) and fBenchmarkTargets
+// (BenchmarkTargets/<target>/Scenario) are understood. The #01-style
+// suffixes go test adds to tell apart sub-benchmarks that share a name are
+// kept, as benchstat does: they are different measurements, not reruns.
+func parseGoTestOutput(r io.Reader, add func(scenario, version string, nsPerOp float64)) error {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		m := benchLineRe.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+		if m == nil {
+			continue
+		}
+		nsPerOp, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(m[1], "/")
+		var version string
+		switch {
+		case legacySuiteRe.MatchString(parts[0]):
+			d := legacySuiteRe.FindStringSubmatch(parts[0])
+			version = d[1] + "." + d[2]
+			parts = parts[1:]
+		case parts[0] == "BenchmarkTargets" && len(parts) > 1:
+			version = parts[1]
+			parts = parts[2:]
+		default:
+			version = strings.TrimPrefix(parts[0], "Benchmark")
+			parts = parts[1:]
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		scenario, suffix := strings.Join(parts, "/"), ""
+		if loc := dupSuffixRe.FindStringIndex(scenario); loc != nil {
+			scenario, suffix = scenario[:loc[0]], scenario[loc[0]:]
+		}
+		scenario = strings.TrimSuffix(scenario, ":") + suffix
+		add(scenario, version, nsPerOp)
+	}
+	return sc.Err()
+}
+
+// compareVersions orders "5.0" < "6.0" < "10.0"; labels that aren't dotted
+// numbers sort after the ones that are.
+func compareVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		switch {
+		case errA != nil || errB != nil:
+			if errA == nil {
+				return -1
+			}
+			if errB == nil {
+				return 1
+			}
+			return strings.Compare(a, b)
+		case na != nb:
+			return na - nb
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// formatNs prints a duration in nanoseconds with three significant digits.
+func formatNs(ns float64) string {
+	units := []struct {
+		scale float64
+		name  string
+	}{{1e9, "s"}, {1e6, "ms"}, {1e3, "µs"}, {1, "ns"}}
+	for _, u := range units {
+		if ns >= u.scale || u.scale == 1 {
+			return strconv.FormatFloat(ns/u.scale, 'g', 3, 64) + u.name
+		}
+	}
+	return ""
+}
+
+// orderedVersions returns the versions oldest first and resolves the
+// baseline, which defaults to the oldest.
+func (s *sampleSet) orderedVersions(baseline string) ([]string, string, error) {
+	if len(s.versions) == 0 {
+		return nil, "", errors.New("no results to compare")
+	}
+	versions := slices.Clone(s.versions)
+	slices.SortFunc(versions, func(a, b string) int {
+		return cmp.Or(compareVersions(s.series(a), s.series(b)), strings.Compare(a, b))
+	})
+	if baseline == "" {
+		baseline = versions[0]
+	}
+	if !slices.Contains(versions, baseline) {
+		return nil, "", fmt.Errorf("baseline %q not found; have %s", baseline, strings.Join(versions, ", "))
+	}
+	return versions, baseline, nil
+}
+
+// series is the release series of the server behind a version label, or
+// the label itself when the results don't say, so targets sort by the
+// version they run.
+func (s *sampleSet) series(label string) string {
+	if m := s.meta[label]; m != nil && len(m.servers) > 0 {
+		return releaseSeries(m.servers[0].Version)
+	}
+	return label
+}
+
+// versionDelta is one version's change against the baseline for a scenario.
+type versionDelta struct {
+	percent float64
+	p       float64
+	n1, n2  int
+}
+
+func (d versionDelta) String(alpha float64) string {
+	if d.n1 < 2 || d.n2 < 2 || d.p > alpha {
+		return fmt.Sprintf("~ (%+.1f%% p=%.3f n=%d+%d)", d.percent, d.p, d.n1, d.n2)
+	}
+	return fmt.Sprintf("%+.1f%% (p=%.3f n=%d+%d)", d.percent, d.p, d.n1, d.n2)
+}
+
+func delta(base, other []float64) versionDelta {
+	return versionDelta{
+		percent: (mean(other)/mean(base) - 1) * 100,
+		p:       mannWhitneyU(base, other),
+		n1:      len(base),
+		n2:      len(other),
+	}
+}
+
+// writeComparison prints one row per scenario with the mean ns/op of every
+// version and, for each non-baseline version, the change against the
+// baseline. Changes whose Mann-Whitney p-value exceeds alpha, or that rest on
+// fewer than two runs a side, are shown as "~" like benchstat does; a zero
+// mean, which has no spread or ratio, shows as "n/a".
+func (s *sampleSet) writeComparison(w io.Writer, baseline string, alpha float64) error {
+	versions, baseline, err := s.orderedVersions(baseline)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "scenario")
+	for _, v := range versions {
+		fmt.Fprintf(tw, "\t%s ns/op", v)
+		if v != baseline {
+			fmt.Fprintf(tw, "\tvs %s", baseline)
+		}
+	}
+	fmt.Fprintln(tw)
+
+	geo := map[string][]float64{}
+	for _, sc := range s.scenarios {
+		base := s.samples[benchKey{sc, baseline}]
+		fmt.Fprint(tw, sc)
+		complete := true
+		for _, v := range versions {
+			xs := s.samples[benchKey{sc, v}]
+			if len(xs) == 0 {
+				complete = false
+				fmt.Fprint(tw, "\t-")
+			} else if m := mean(xs); m == 0 {
+				fmt.Fprint(tw, "\tn/a")
+			} else {
+				fmt.Fprintf(tw, "\t%s ±%.0f%%", formatNs(m), stddev(xs)/m*100)
+			}
+			if v == baseline {
+				continue
+			}
+			switch {
+			case len(xs) == 0 || len(base) == 0:
+				fmt.Fprint(tw, "\t")
+			case mean(base) == 0:
+				fmt.Fprint(tw, "\tn/a")
+			default:
+				fmt.Fprintf(tw, "\t%s", delta(base, xs).String(alpha))
+			}
+		}
+		fmt.Fprintln(tw)
+
+		// The geomean only covers scenarios every version has.
+		if complete {
+			for _, v := range versions {
+				geo[v] = append(geo[v], mean(s.samples[benchKey{sc, v}]))
+			}
+		}
+	}
+
+	if len(geo[baseline]) > 1 {
+		fmt.Fprint(tw, "geomean")
+		baseGeo := geomean(geo[baseline])
+		for _, v := range versions {
+			g := geomean(geo[v])
+			fmt.Fprintf(tw, "\t%s", formatNs(g))
+			switch {
+			case v == baseline:
+			case baseGeo == 0:
+				fmt.Fprint(tw, "\tn/a")
+			default:
+				fmt.Fprintf(tw, "\t%+.1f%%", (g/baseGeo-1)*100)
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, sc := range s.scenarios {
+		if changes := s.planChanges(sc, versions, baseline); len(changes) > 0 {
+			fmt.Fprintf(w, "\n%s plan changes against %s:\n", sc, baseline)
+			for _, c := range changes {
+				fmt.Fprintf(w, "  %s\n", c)
+			}
+		}
+	}
+	return nil
+}
+
+// writeSummary prints the spread of every scenario's runs on every version:
+// mean, median, standard deviation, coefficient of variation and the 95%
+// confidence interval of the mean ns/op.
+func (s *sampleSet) writeSummary(w io.Writer) error {
+	versions, _, err := s.orderedVersions("")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\nns/op over repeated runs")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "scenario\tversion\truns\tmean\tmedian\tstddev\tCV\t95% CI")
+	for _, sc := range s.scenarios {
+		for _, v := range versions {
+			xs := s.samples[benchKey{sc, v}]
+			if len(xs) == 0 {
+				continue
+			}
+			lo, hi := confidenceInterval(xs)
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%.1f%%\t%s .. %s\n", sc, v, len(xs),
+				formatNs(mean(xs)), formatNs(median(xs)), formatNs(stddev(xs)), cv(xs)*100,
+				formatNs(math.Max(0, lo)), formatNs(hi))
+		}
+	}
+	return tw.Flush()
+}
+
+// planChanges describes every version whose winning plan for the scenario
+// differs from the baseline's.
+func (s *sampleSet) planChanges(scenario string, versions []string, baseline string) []string {
+	base := s.plans[benchKey{scenario, baseline}]
+	if base == nil {
+		return nil
+	}
+	var changes []string
+	for _, v := range versions {
+		p := s.plans[benchKey{scenario, v}]
+		if v == baseline || p == nil || p.WinningPlan == base.WinningPlan {
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s (keys %d, docs %d) instead of %s (keys %d, docs %d)",
+			v, p.WinningPlan, p.KeysExamined, p.DocsExamined, base.WinningPlan, base.KeysExamined, base.DocsExamined))
+	}
+	return changes
+}
+
+func compareCommand(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	baseline := fs.String("baseline", "", "version to compare against, a target name or for go test logs a release series (default the oldest)")
+	alpha := fs.Float64("alpha", 0.05, "significance level for the Mann-Whitney U test")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: mongoVersionSpeedTest compare [flags] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("compare needs at least one result file")
+	}
+
+	set := newSampleSet()
+	for _, path := range fs.Args() {
+		if err := set.loadFile(path); err != nil {
+			return err
+		}
+	}
+	if len(set.samples) == 0 {
+		return errors.New("no benchmark results found")
+	}
+	return set.writeComparison(os.Stdout, *baseline, *alpha)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGoTestOutput(t *testing.T) {
+	out := `Connected to Mongo50
+goos: linux
+BenchmarkMongo50/InsertManyMillion:-12         	       1	5774206795 ns/op
+BenchmarkMongo50/FindOneByIdWithDeserialization:#01-12            	       1	3407653988 ns/op
+BenchmarkTargets/mongo82/UpdateOne-8   	    9000	    130000 ns/op	      7692 ops/s
+PASS
+`
+	type sample struct {
+		scenario, version string
+		ns                float64
+	}
+	var got []sample
+	err := parseGoTestOutput(strings.NewReader(out), func(scenario, version string, ns float64) {
+		got = append(got, sample{scenario, version, ns})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sample{
+		{"InsertManyMillion", "5.0", 5774206795},
+		{"FindOneByIdWithDeserialization#01", "5.0", 3407653988},
+		{"UpdateOne", "mongo82", 130000},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAddResultGroupsByTarget(t *testing.T) {
+	set := newSampleSet()
+	for _, target := range []string{"mongo80-rs", "mongo80", "mongo50", "mongo80-rs"} {
+		version := "8.0.4"
+		if target == "mongo50" {
+			version = "5.0.14"
+		}
+		set.addResult(result{Target: target, Server: &serverInfo{Version: version}, Scenario: "FindOne", Iterations: 10, NsPerOp: 1000})
+	}
+	// Failed runs carry no timing and aren't samples.
+	set.addResult(result{Target: "mongo80", Server: &serverInfo{Version: "8.0.4"}, Scenario: "FindOne", Errors: 1})
+	set.addResult(result{Target: "mongo80", Server: &serverInfo{Version: "8.0.4"}, Scenario: "UpdateOne", Iterations: 10, Errors: 10})
+
+	versions, baseline, err := set.orderedVersions("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"mongo50", "mongo80", "mongo80-rs"}; !slices.Equal(versions, want) || baseline != "mongo50" {
+		t.Errorf("versions %v baseline %s, want %v baseline mongo50", versions, baseline, want)
+	}
+	if n := len(set.samples[benchKey{"FindOne", "mongo80"}]); n != 1 {
+		t.Errorf("mongo80 has %d FindOne samples, want 1", n)
+	}
+	if n := len(set.samples[benchKey{"FindOne", "mongo80-rs"}]); n != 2 {
+		t.Errorf("mongo80-rs has %d FindOne samples, want 2", n)
+	}
+	if slices.Contains(set.scenarios, "UpdateOne") {
+		t.Error("failed UpdateOne run counted as a sample")
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		xs, ys []float64
+		want   float64
+	}{
+		// Complete separation of 3+3 samples: 2 of 20 orderings are as extreme.
+		{[]float64{1, 2, 3}, []float64{4, 5, 6}, 0.1},
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 2.0 / 252},
+		{[]float64{1, 3, 5}, []float64{2, 4, 6}, 0.7},
+	}
+	for _, tt := range tests {
+		if got := mannWhitneyU(tt.xs, tt.ys); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("mannWhitneyU(%v, %v) = %v, want %v", tt.xs, tt.ys, got, tt.want)
+		}
+	}
+
+	// With ties the normal approximation is used; identical samples can't differ.
+	if p := mannWhitneyU([]float64{1, 1, 1}, []float64{1, 1, 1}); p != 1 {
+		t.Errorf("identical samples: p = %v, want 1", p)
+	}
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	// Mean 101000, stddev 1000, t(2) = 4.303.
+	lo, hi := confidenceInterval([]float64{100000, 101000, 102000})
+	if math.Abs(lo-98515.7) > 0.1 || math.Abs(hi-103484.3) > 0.1 {
+		t.Errorf("interval %v .. %v", lo, hi)
+	}
+	if lo, hi := confidenceInterval([]float64{5}); lo != 5 || hi != 5 {
+		t.Errorf("single sample: interval %v .. %v, want the sample", lo, hi)
+	}
+
+	// Beyond the table the expansion stays close to the exact quantile,
+	// 1.984 for 100 degrees of freedom.
+	xs := make([]float64, 101)
+	for i := range xs {
+		xs[i] = float64(i % 2)
+	}
+	lo, hi = confidenceInterval(xs)
+	if want := 1.984 * stddev(xs) / math.Sqrt(101); math.Abs((hi-lo)/2-want) > 0.001 {
+		t.Errorf("half width %v, want %v", (hi-lo)/2, want)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	set := newSampleSet()
+	for _, ns := range []float64{100000, 101000, 102000} {
+		set.add("FindOne", "7.0", ns)
+	}
+	var out bytes.Buffer
+	if err := set.writeSummary(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FindOne", "7.0", "101µs", "1.0%", "98.5µs .. 103µs"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteSummaryWithoutResults(t *testing.T) {
+	if err := newSampleSet().writeSummary(io.Discard); err == nil {
+		t.Error("summary of no results succeeded")
+	}
+}
+
+func TestWriteComparisonZeroBaseline(t *testing.T) {
+	set := newSampleSet()
+	for _, ns := range []float64{0, 0} {
+		set.add("FindOne", "5.0", ns)
+		set.add("UpdateOne", "5.0", ns+100)
+	}
+	for _, ns := range []float64{100, 110} {
+		set.add("FindOne", "8.0", ns)
+		set.add("UpdateOne", "8.0", ns)
+	}
+	var out bytes.Buffer
+	if err := set.writeComparison(&out, "5.0", 0.05); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "NaN") || strings.Contains(out.String(), "Inf") {
+		t.Errorf("comparison against a zero baseline divides by zero:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "n/a") {
+		t.Errorf("comparison doesn't mark the zero baseline n/a:\n%s", out.String())
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	versions := []string{"mongo82", "10.0", "8.0", "5.0", "6.0"}
+	slices.SortFunc(versions, compareVersions)
+	want := []string{"5.0", "6.0", "8.0", "10.0", "mongo82"}
+	if !slices.Equal(versions, want) {
+		t.Errorf("sorted %v, want %v", versions, want)
+	}
+}
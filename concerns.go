@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+// concernDefault leaves a setting to the driver and server defaults.
+const concernDefault = "default"
+
+// concernSetting is one cell of the -write-concerns x -read-concerns x
+// -read-prefs matrix. Empty fields mean the driver default.
+type concernSetting struct {
+	writeConcern string
+	readConcern  string
+	readPref     string
+}
+
+// concernMatrix returns every combination of the configured settings, in
+// flag order with the write concern varying slowest.
+func concernMatrix(cfg *runConfig) []concernSetting {
+	var matrix []concernSetting
+	for _, w := range cfg.writeConcerns {
+		for _, r := range cfg.readConcerns {
+			for _, p := range cfg.readPrefs {
+				matrix = append(matrix, concernSetting{w, r, p})
+			}
+		}
+	}
+	return matrix
+}
+
+// params are the result params that label runs with this setting. Defaults
+// are left out so plain runs keep their scenario names.
+func (cs concernSetting) params() map[string]string {
+	params := map[string]string{}
+	if cs.writeConcern != "" {
+		params["writeConcern"] = cs.writeConcern
+	}
+	if cs.readConcern != "" {
+		params["readConcern"] = cs.readConcern
+	}
+	if cs.readPref != "" {
+		params["readPref"] = cs.readPref
+	}
+	return params
+}
+
+// parseWriteConcern understands a w value (0, 1, 2, ... or majority), j for
+// journaled acknowledgement, or both joined with +, e.g. majority+j.
+func parseWriteConcern(s string) (*writeconcern.WriteConcern, error) {
+	if s == "" {
+		return nil, nil
+	}
+	wc := &writeconcern.WriteConcern{}
+	for _, part := range strings.Split(s, "+") {
+		switch part {
+		case "j":
+			journal := true
+			wc.Journal = &journal
+		case "majority":
+			wc.W = writeconcern.WCMajority
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid write concern %q", s)
+			}
+			wc.W = n
+		}
+	}
+	if !wc.IsValid() {
+		return nil, fmt.Errorf("invalid write concern %q", s)
+	}
+	return wc, nil
+}
+
+func parseReadConcern(s string) (*readconcern.ReadConcern, error) {
+	switch s {
+	case "":
+		return nil, nil
+	case "local":
+		return readconcern.Local(), nil
+	case "available":
+		return readconcern.Available(), nil
+	case "majority":
+		return readconcern.Majority(), nil
+	case "linearizable":
+		return readconcern.Linearizable(), nil
+	case "snapshot":
+		return readconcern.Snapshot(), nil
+	}
+	return nil, fmt.Errorf("invalid read concern %q", s)
+}
+
+func parseReadPref(s string) (*readpref.ReadPref, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mode, err := readpref.ModeFromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid read preference %q", s)
+	}
+	return readpref.New(mode)
+}
+
+// parseConcernList parses a comma-separated flag value with parse, mapping
+// "default" to the empty setting.
+func parseConcernList[T any](s string, parse func(string) (T, error)) ([]string, error) {
+	var settings []string
+	for _, item := range splitList(s) {
+		if item == concernDefault {
+			item = ""
+		}
+		if _, err := parse(item); err != nil {
+			return nil, err
+		}
+		settings = append(settings, item)
+	}
+	if len(settings) == 0 {
+		settings = []string{""}
+	}
+	return settings, nil
+}
+
+// databaseOptions applies the setting to the databases scenarios use; their
+// collections inherit it. The strings were validated when the flags were
+// parsed.
+func (cs concernSetting) databaseOptions() *options.DatabaseOptionsBuilder {
+	opts := options.Database()
+	if wc, _ := parseWriteConcern(cs.writeConcern); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+	if rc, _ := parseReadConcern(cs.readConcern); rc != nil {
+		opts.SetReadConcern(rc)
+	}
+	if rp, _ := parseReadPref(cs.readPref); rp != nil {
+		opts.SetReadPreference(rp)
+	}
+	return opts
+}
+
+func (cs concernSetting) database(client *mongo.Client, database string) *mongo.Database {
+	return client.Database(database, cs.databaseOptions())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+)
+
+func TestParseWriteConcern(t *testing.T) {
+	wc, err := parseWriteConcern("majority+j")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wc.W != writeconcern.WCMajority || wc.Journal == nil || !*wc.Journal {
+		t.Errorf("majority+j parsed as %+v", wc)
+	}
+	if wc, err = parseWriteConcern("1"); err != nil || wc.W != 1 || wc.Journal != nil {
+		t.Errorf("1 parsed as %+v, %v", wc, err)
+	}
+	if _, err := parseWriteConcern("0+j"); err == nil {
+		t.Error("unacknowledged journaled write concern accepted")
+	}
+	if _, err := parseWriteConcern("most"); err == nil {
+		t.Error("unknown write concern accepted")
+	}
+}
+
+func TestConcernMatrix(t *testing.T) {
+	cfg := defaultRunConfig()
+	var err error
+	if cfg.writeConcerns, err = parseConcernList("default,majority", parseWriteConcern); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.readConcerns, err = parseConcernList("local,snapshot", parseReadConcern); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.readPrefs, err = parseConcernList("", parseReadPref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseConcernList("primary,fastest", parseReadPref); err == nil {
+		t.Error("unknown read preference accepted")
+	}
+
+	matrix := concernMatrix(cfg)
+	if len(matrix) != 4 {
+		t.Fatalf("got %d settings, want 4: %+v", len(matrix), matrix)
+	}
+	first := result{Scenario: "FindOne", Params: matrix[0].params()}
+	if got := first.variant(); got != "FindOne[readConcern=local]" {
+		t.Errorf("first label %q", got)
+	}
+	last := result{Scenario: "FindOne", Params: matrix[3].params()}
+	if got := last.variant(); got != "FindOne[readConcern=snapshot,writeConcern=majority]" {
+		t.Errorf("last label %q", got)
+	}
+}
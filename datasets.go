@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// collectionState is what a scenario needs the main collection to hold.
+type collectionState int
+
+const (
+	// collectionAny leaves the collection as it is, for scenarios that don't
+	// use it.
+	collectionAny collectionState = iota
+	// collectionEmpty drops the collection.
+	collectionEmpty
+	// collectionSeeded holds generated documents 1..-docs.
+	collectionSeeded
+)
+
+// documentSet picks the collection a dataset describes and the documents it
+// is seeded with.
+type documentSet int
+
+const (
+	// mainDocs is the main collection with -docs documents from -docgen.
+	mainDocs documentSet = iota
+	// indexSuiteDocs is the index suite's collection with -index-docs
+	// documents from indexDocTemplate.
+	indexSuiteDocs
+	// aggregationDocs is the aggregation suite's collection with -agg-docs
+	// documents from indexDocTemplate.
+	aggregationDocs
+)
+
+// dataset declares the data a scenario measures against. The runner seeds it
+// before the scenario's setup, outside the timer, so no scenario depends on
+// what ran before it.
+type dataset struct {
+	collection collectionState
+	docs       documentSet
+	// docCount, when set, replaces -docs as the size of the main collection.
+	docCount int
+	// updated marks the first -n seeded documents updated: true, as a run
+	// of UpdateOne does.
+	updated bool
+	// indexes are the secondary indexes a seeded or empty collection has;
+	// any others are dropped.
+	indexes []indexSpec
+	// categories seeds the collection the aggregation suite's $lookup joins.
+	categories bool
+	// gridFSFile makes sure -file is in the GridFS bucket.
+	gridFSFile bool
+}
+
+// seedBatch is how many documents one seeding InsertMany writes.
+const seedBatch = 10000
+
+// source returns the collection ds describes with the generator and number
+// of documents it is seeded from.
+func (ds dataset) source(env *runEnv) (*mongo.Collection, *docGenerator, int) {
+	switch ds.docs {
+	case indexSuiteDocs:
+		return env.indexColl(), env.cfg.indexGen, env.cfg.indexDocs
+	case aggregationDocs:
+		return env.aggColl(), env.cfg.indexGen, env.cfg.aggDocs
+	}
+	if ds.docCount > 0 {
+		return env.coll, env.gen, ds.docCount
+	}
+	return env.coll, env.gen, env.cfg.docs
+}
+
+// seedDataset brings the target to ds. It checks what is already there first,
+// so consecutive scenarios that want the same data don't reseed it.
+func seedDataset(ctx context.Context, env *runEnv, ds dataset) error {
+	coll, gen, docs := ds.source(env)
+	switch ds.collection {
+	case collectionEmpty:
+		if err := dropCollection(ctx, coll); err != nil {
+			return err
+		}
+		if err := seedIndexes(ctx, coll, ds.indexes); err != nil {
+			return err
+		}
+	case collectionSeeded:
+		if err := seedDocuments(ctx, coll, gen, docs); err != nil {
+			return err
+		}
+		want := 0
+		if ds.updated {
+			want = min(env.cfg.iterations, docs)
+		}
+		if err := seedUpdated(ctx, coll, gen, want); err != nil {
+			return err
+		}
+		if err := seedIndexes(ctx, coll, ds.indexes); err != nil {
+			return err
+		}
+	}
+	if ds.categories {
+		if err := seedCategories(ctx, env.categoryColl()); err != nil {
+			return err
+		}
+	}
+	if ds.gridFSFile {
+		return seedGridFSFile(ctx, env)
+	}
+	return nil
+}
+
+// seedDocuments fills coll with documents 1..docs unless it already holds
+// exactly those.
+func seedDocuments(ctx context.Context, coll *mongo.Collection, gen *docGenerator, docs int) error {
+	if n, err := coll.EstimatedDocumentCount(ctx); err == nil && n == int64(docs) {
+		ids := bson.A{gen.id(1), gen.id(docs)}
+		found, err := coll.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err == nil && found == int64(len(ids)) {
+			return nil
+		}
+	}
+
+	start := time.Now()
+	if err := dropCollection(ctx, coll); err != nil {
+		return err
+	}
+	for first := 1; first <= docs; first += seedBatch {
+		if err := insertMany(ctx, coll, gen.docs(first, min(seedBatch, docs-first+1)), false); err != nil {
+			return err
+		}
+	}
+	log.Printf("Seeded %d documents in %s", docs, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// seedUpdated flags the first want documents updated: true and no others.
+func seedUpdated(ctx context.Context, coll *mongo.Collection, gen *docGenerator, want int) error {
+	filter := bson.M{"updated": true}
+	if n, err := coll.CountDocuments(ctx, filter); err == nil && n == int64(want) {
+		if want == 0 {
+			return nil
+		}
+		// The right count can still be the wrong documents.
+		n, err := coll.CountDocuments(ctx, bson.M{"_id": gen.id(1), "updated": true})
+		if err == nil && n == 1 {
+			return nil
+		}
+	}
+
+	if _, err := coll.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"updated": ""}}); err != nil {
+		return err
+	}
+	for first := 1; first <= want; first += seedBatch {
+		ids := make(bson.A, 0, seedBatch)
+		for i := first; i < first+seedBatch && i <= want; i++ {
+			ids = append(ids, gen.id(i))
+		}
+		update := bson.M{"$set": bson.M{"updated": true}}
+		if _, err := coll.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedIndexes leaves exactly the given indexes next to _id.
+func seedIndexes(ctx context.Context, coll *mongo.Collection, specs []indexSpec) error {
+	wanted := map[string]indexSpec{}
+	for _, spec := range specs {
+		wanted[spec.name] = spec
+	}
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var existing []struct {
+		Name string `bson:"name"`
+	}
+	if err := cursor.All(ctx, &existing); err != nil {
+		return err
+	}
+	var have []string
+	for _, idx := range existing {
+		if idx.Name == "_id_" {
+			continue
+		}
+		if _, ok := wanted[idx.Name]; ok {
+			have = append(have, idx.Name)
+			continue
+		}
+		if err := coll.Indexes().DropOne(ctx, idx.Name); err != nil {
+			return fmt.Errorf("dropping index %s: %w", idx.Name, err)
+		}
+	}
+
+	for _, spec := range specs {
+		if slices.Contains(have, spec.name) {
+			continue
+		}
+		if err := createIndex(ctx, coll, spec); err != nil {
+			return fmt.Errorf("creating index %s: %w", spec.name, err)
+		}
+	}
+	return nil
+}
+
+// seedCategories fills coll with one document per index suite category
+// unless it already has them.
+func seedCategories(ctx context.Context, coll *mongo.Collection) error {
+	if n, err := coll.CountDocuments(ctx, bson.M{}); err == nil && n == int64(len(indexCategories)) {
+		return nil
+	}
+	if err := dropCollection(ctx, coll); err != nil {
+		return err
+	}
+	docs := make([]any, len(indexCategories))
+	for i, name := range indexCategories {
+		docs[i] = bson.D{
+			{Key: "_id", Value: name},
+			{Key: "owner", Value: pick(indexWords, i)},
+			{Key: "retentionDays", Value: 30 * (i + 1)},
+		}
+	}
+	return insertMany(ctx, coll, docs, true)
+}
+
+// seedGridFSFile uploads -file unless the bucket already has it.
+func seedGridFSFile(ctx context.Context, env *runEnv) error {
+	n, err := env.gridFS.Collection("fs.files").CountDocuments(ctx, bson.M{"filename": gridFSFileName})
+	if err == nil && n > 0 {
+		return nil
+	}
+	return gridFSUploadFromStream(ctx, env.gridFS, env.cfg.filePath)
+}
+
+// prepareScenario runs everything before the measured iterations: seeding
+// the declared dataset, then the scenario's own setup.
+func prepareScenario(ctx context.Context, env *runEnv, sc scenario) error {
+	if err := seedScenario(ctx, env, sc); err != nil {
+		return err
+	}
+	if sc.setup != nil {
+		if err := sc.setup(ctx, env); err != nil {
+			return fmt.Errorf("setup: %w", err)
+		}
+	}
+	return nil
+}
+
+// seedScenario seeds the scenario's dataset with the driver's default
+// concerns.
+func seedScenario(ctx context.Context, env *runEnv, sc scenario) error {
+	if err := seedDataset(ctx, env.withConcerns(concernSetting{}), sc.dataset); err != nil {
+		return fmt.Errorf("dataset: %w", err)
+	}
+	return nil
+}
+
+// finishScenario runs the scenario's teardown after the measured iterations.
+func finishScenario(ctx context.Context, env *runEnv, sc scenario) error {
+	if sc.teardown == nil {
+		return nil
+	}
+	if err := sc.teardown(ctx, env); err != nil {
+		return fmt.Errorf("teardown: %w", err)
+	}
+	return nil
+}
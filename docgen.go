@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"gopkg.in/yaml.v3"
+)
+
+// docTemplate describes the documents insert scenarios write. It is loaded
+// from a YAML/JSON file given with -docgen; defaultDocTemplate reproduces the
+// documents the suites always inserted.
+type docTemplate struct {
+	// Seed makes generation reproducible: document i is the same for a
+	// given seed no matter which worker or run generates it.
+	Seed uint64 `yaml:"seed"`
+	// IDs is how _id values are made: sequential ("<idPrefix><i>"), random
+	// (hex strings) or objectid. All three are derived from i, so scenarios
+	// can look document i up again.
+	IDs      string      `yaml:"ids"`
+	IDPrefix string      `yaml:"idPrefix"`
+	Size     sizeSpec    `yaml:"size"`
+	Fields   []fieldSpec `yaml:"fields"`
+}
+
+// sizeSpec adds a "padding" string field whose length follows a
+// distribution, to spread document sizes.
+type sizeSpec struct {
+	// Distribution is fixed, uniform or normal; empty means no padding.
+	Distribution string  `yaml:"distribution"`
+	Min          int     `yaml:"min"`
+	Max          int     `yaml:"max"`
+	Mean         float64 `yaml:"mean"`
+	Stddev       float64 `yaml:"stddev"`
+}
+
+// fieldSpec describes one generated field. Which options apply depends on
+// Type:
+//
+//	string    pattern (fmt verb %d receives i), values, length or minLength/maxLength
+//	int       sequence (use i), or min/max
+//	double    min/max
+//	bool      p, the probability of true
+//	date      daysBack spreads dates over the days before docEpoch; 0 means docEpoch
+//	objectId  random ObjectID
+//	object    fields
+//	array     items, minItems/maxItems
+type fieldSpec struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"`
+	Pattern     string      `yaml:"pattern,omitempty"`
+	Values      []string    `yaml:"values,omitempty"`
+	Length      int         `yaml:"length,omitempty"`
+	MinLength   int         `yaml:"minLength,omitempty"`
+	MaxLength   int         `yaml:"maxLength,omitempty"`
+	Sequence    bool        `yaml:"sequence,omitempty"`
+	Min         float64     `yaml:"min,omitempty"`
+	Max         float64     `yaml:"max,omitempty"`
+	Probability float64     `yaml:"p,omitempty"`
+	DaysBack    int         `yaml:"daysBack,omitempty"`
+	Fields      []fieldSpec `yaml:"fields,omitempty"`
+	Items       *fieldSpec  `yaml:"items,omitempty"`
+	MinItems    int         `yaml:"minItems,omitempty"`
+	MaxItems    int         `yaml:"maxItems,omitempty"`
+}
+
+const (
+	idsSequential = "sequential"
+	idsRandom     = "random"
+	idsObjectID   = "objectid"
+)
+
+func defaultDocTemplate() *docTemplate {
+	return &docTemplate{
+		Seed:     1,
+		IDs:      idsSequential,
+		IDPrefix: "fafa",
+		Fields: []fieldSpec{
+			{Name: "fileName", Type: "string", Pattern: "fakeFile.fake%d"},
+			{Name: "editDate", Type: "date"},
+			{Name: "count", Type: "int", Sequence: true},
+		},
+	}
+}
+
+func loadDocTemplate(path string) (*docTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := defaultDocTemplate()
+	t.Fields = nil
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+func (t *docTemplate) validate() error {
+	switch t.IDs {
+	case idsSequential, idsRandom, idsObjectID:
+	default:
+		return fmt.Errorf("unknown ids mode %q", t.IDs)
+	}
+	switch t.Size.Distribution {
+	case "", "fixed", "uniform", "normal":
+	default:
+		return fmt.Errorf("unknown size distribution %q", t.Size.Distribution)
+	}
+	return validateFields(t.Fields)
+}
+
+func validateFields(fields []fieldSpec) error {
+	for _, f := range fields {
+		if f.Name == "" {
+			return fmt.Errorf("field without a name")
+		}
+		if err := validateField(f); err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateField(f fieldSpec) error {
+	switch f.Type {
+	case "string", "int", "double", "bool", "date", "objectId":
+		return nil
+	case "object":
+		return validateFields(f.Fields)
+	case "array":
+		if f.Items == nil {
+			return fmt.Errorf("array without items")
+		}
+		return validateField(*f.Items)
+	}
+	return fmt.Errorf("unknown type %q", f.Type)
+}
+
+// docEpoch is what generated dates and ObjectID timestamps count from.
+// It is fixed rather than the current time so a seed always gives the same
+// documents.
+var docEpoch = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// docGenerator turns a template into documents and ids.
+type docGenerator struct {
+	tmpl *docTemplate
+
+	sizeOnce sync.Once
+	avgSize  float64
+}
+
+func newDocGenerator(tmpl *docTemplate) *docGenerator {
+	return &docGenerator{tmpl: tmpl}
+}
+
+// rng returns the random source for document i, so each document depends
+// only on the seed and i.
+func (g *docGenerator) rng(i int, stream uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(g.tmpl.Seed^stream, uint64(i)))
+}
+
+func (g *docGenerator) idHash(i int) uint64 {
+	h := fnv.New64a()
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], g.tmpl.Seed)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(i))
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+// id returns the _id of document i.
+func (g *docGenerator) id(i int) any {
+	switch g.tmpl.IDs {
+	case idsRandom:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], g.idHash(i))
+		return hex.EncodeToString(b[:])
+	case idsObjectID:
+		// Timestamp part from docEpoch, the rest from i, so ids are unique
+		// per i and can be rebuilt.
+		var oid bson.ObjectID
+		binary.BigEndian.PutUint32(oid[:4], uint32(docEpoch.Unix()))
+		binary.BigEndian.PutUint64(oid[4:], g.idHash(i))
+		return oid
+	}
+	return fmt.Sprintf("%s%d", g.tmpl.IDPrefix, i)
+}
+
+// doc returns document i, _id first.
+func (g *docGenerator) doc(i int) bson.D {
+	r := g.rng(i, 0)
+	d := make(bson.D, 0, len(g.tmpl.Fields)+2)
+	d = append(d, bson.E{Key: "_id", Value: g.id(i)})
+	d = append(d, g.fields(r, g.tmpl.Fields, i)...)
+	if n := g.paddingLength(r); n > 0 {
+		d = append(d, bson.E{Key: "padding", Value: randomString(r, n)})
+	}
+	return d
+}
+
+// docs returns documents first..first+count-1 ready for InsertMany.
+func (g *docGenerator) docs(first, count int) []any {
+	docs := make([]any, 0, count)
+	for i := first; i < first+count; i++ {
+		docs = append(docs, g.doc(i))
+	}
+	return docs
+}
+
+func (g *docGenerator) fields(r *rand.Rand, specs []fieldSpec, i int) bson.D {
+	d := make(bson.D, 0, len(specs))
+	for _, f := range specs {
+		d = append(d, bson.E{Key: f.Name, Value: g.value(r, f, i)})
+	}
+	return d
+}
+
+func (g *docGenerator) value(r *rand.Rand, f fieldSpec, i int) any {
+	switch f.Type {
+	case "string":
+		switch {
+		case f.Pattern != "":
+			return fmt.Sprintf(f.Pattern, i)
+		case len(f.Values) > 0:
+			return f.Values[r.IntN(len(f.Values))]
+		case f.MaxLength > f.MinLength:
+			return randomString(r, f.MinLength+r.IntN(f.MaxLength-f.MinLength+1))
+		}
+		return randomString(r, max(f.Length, f.MinLength, 8))
+	case "int":
+		if f.Sequence {
+			return i
+		}
+		if f.Max > f.Min {
+			return int64(f.Min) + r.Int64N(int64(f.Max-f.Min)+1)
+		}
+		return int64(f.Min)
+	case "double":
+		if f.Max > f.Min {
+			return f.Min + r.Float64()*(f.Max-f.Min)
+		}
+		return f.Min
+	case "bool":
+		return r.Float64() < f.Probability
+	case "date":
+		if f.DaysBack > 0 {
+			return docEpoch.Add(-time.Duration(r.Int64N(int64(f.DaysBack) * int64(24*time.Hour))))
+		}
+		return docEpoch
+	case "objectId":
+		var oid bson.ObjectID
+		binary.BigEndian.PutUint32(oid[:4], uint32(docEpoch.Unix()))
+		binary.BigEndian.PutUint64(oid[4:], r.Uint64())
+		return oid
+	case "object":
+		return g.fields(r, f.Fields, i)
+	case "array":
+		n := f.MinItems
+		if f.MaxItems > f.MinItems {
+			n += r.IntN(f.MaxItems - f.MinItems + 1)
+		}
+		items := make(bson.A, n)
+		for k := range items {
+			items[k] = g.value(r, *f.Items, i)
+		}
+		return items
+	}
+	return nil
+}
+
+func (g *docGenerator) paddingLength(r *rand.Rand) int {
+	s := g.tmpl.Size
+	switch s.Distribution {
+	case "fixed":
+		return s.Min
+	case "uniform":
+		if s.Max > s.Min {
+			return s.Min + r.IntN(s.Max-s.Min+1)
+		}
+		return s.Min
+	case "normal":
+		n := int(math.Round(r.NormFloat64()*s.Stddev + s.Mean))
+		if s.Max > 0 {
+			n = min(n, s.Max)
+		}
+		return max(n, s.Min, 0)
+	}
+	return 0
+}
+
+const randomAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomString(r *rand.Rand, n int) string {
+	var b strings.Builder
+	b.Grow(n)
+	for range n {
+		b.WriteByte(randomAlphabet[r.IntN(len(randomAlphabet))])
+	}
+	return b.String()
+}
+
+// avgDocSize estimates the mean BSON size of generated documents from a
+// sample, for MB/s figures.
+func (g *docGenerator) avgDocSize() float64 {
+	g.sizeOnce.Do(func() {
+		const sample = 1000
+		var total int
+		for i := 1; i <= sample; i++ {
+			raw, err := bson.Marshal(g.doc(i))
+			if err != nil {
+				return
+			}
+			total += len(raw)
+		}
+		g.avgSize = float64(total) / sample
+	})
+	return g.avgSize
+}
+
+// isDefault reports whether documents have the shape of myFile, so finds can
+// keep decoding into it.
+func (g *docGenerator) isDefault() bool {
+	def := defaultDocTemplate()
+	if g.tmpl.IDs != idsSequential || g.tmpl.Size.Distribution != "" || len(g.tmpl.Fields) != len(def.Fields) {
+		return false
+	}
+	for i, f := range g.tmpl.Fields {
+		if f.Name != def.Fields[i].Name || f.Type != def.Fields[i].Type {
+			return false
+		}
+	}
+	return true
+}
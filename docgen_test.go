@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDefaultTemplateMatchesMyFile(t *testing.T) {
+	g := newDocGenerator(defaultDocTemplate())
+	if !g.isDefault() {
+		t.Fatal("default template not recognised as default")
+	}
+
+	raw, err := bson.Marshal(g.doc(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f myFile
+	if err := bson.Unmarshal(raw, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Id != "fafa7" || f.FileName != "fakeFile.fake7" || f.Count != 7 {
+		t.Errorf("unexpected document %+v", f)
+	}
+}
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	tmpl, err := loadDocTemplate(filepath.Join(".", "docgen.example.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := newDocGenerator(tmpl), newDocGenerator(tmpl)
+
+	for _, i := range []int{1, 2, 1000} {
+		ra, _ := bson.Marshal(a.doc(i))
+		rb, _ := bson.Marshal(b.doc(i))
+		if string(ra) != string(rb) {
+			t.Errorf("document %d differs between generators with the same seed", i)
+		}
+	}
+	if a.id(1) == a.id(2) {
+		t.Error("random ids collide")
+	}
+
+	other := *tmpl
+	other.Seed++
+	c := newDocGenerator(&other)
+	if c.id(1) == a.id(1) {
+		t.Error("different seeds gave the same id")
+	}
+}
+
+func TestIDModes(t *testing.T) {
+	for _, mode := range []string{idsSequential, idsRandom, idsObjectID} {
+		tmpl := defaultDocTemplate()
+		tmpl.IDs = mode
+		g := newDocGenerator(tmpl)
+		if g.doc(5)[0].Value != g.id(5) {
+			t.Errorf("%s: document _id doesn't match id()", mode)
+		}
+	}
+	oid, ok := newDocGenerator(&docTemplate{IDs: idsObjectID}).id(1).(bson.ObjectID)
+	if !ok {
+		t.Error("objectid mode doesn't produce ObjectIDs")
+	}
+	if !oid.Timestamp().Equal(docEpoch) {
+		t.Errorf("ObjectID timestamp %v, want %v", oid.Timestamp(), docEpoch)
+	}
+}
+
+func TestPaddingDistribution(t *testing.T) {
+	tmpl := defaultDocTemplate()
+	tmpl.Size = sizeSpec{Distribution: "uniform", Min: 100, Max: 200}
+	g := newDocGenerator(tmpl)
+	for i := 1; i <= 200; i++ {
+		d := g.doc(i)
+		pad, ok := d[len(d)-1].Value.(string)
+		if !ok || len(pad) < 100 || len(pad) > 200 {
+			t.Fatalf("document %d padding length %d outside [100, 200]", i, len(pad))
+		}
+	}
+}
+
+func TestTemplateValidation(t *testing.T) {
+	tmpl := defaultDocTemplate()
+	tmpl.Fields = append(tmpl.Fields, fieldSpec{Name: "x", Type: "array"})
+	if err := tmpl.validate(); err == nil {
+		t.Error("array without items accepted")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// queryPlan is what explain("executionStats") says about a scenario's query
+// on one target.
+type queryPlan struct {
+	// WinningPlan is the plan's stage tree, e.g. "LIMIT > FETCH > IXSCAN(updated_1)".
+	WinningPlan     string      `json:"winningPlan"`
+	NReturned       int64       `json:"nReturned"`
+	KeysExamined    int64       `json:"keysExamined"`
+	DocsExamined    int64       `json:"docsExamined"`
+	ExecutionTimeMs int64       `json:"executionTimeMs"`
+	Stages          []planStage `json:"stages,omitempty"`
+}
+
+// planStage is one execution or pipeline stage with its own counters.
+type planStage struct {
+	Stage        string `json:"stage"`
+	NReturned    int64  `json:"nReturned"`
+	TimeMs       int64  `json:"timeMsEstimate"`
+	KeysExamined int64  `json:"keysExamined,omitempty"`
+	DocsExamined int64  `json:"docsExamined,omitempty"`
+}
+
+// findCommand is the find command coll.Find(filter) with a limit sends, for
+// explaining. limit 0 means none.
+func findCommand(coll *mongo.Collection, filter any, limit int64) bson.D {
+	cmd := bson.D{{Key: "find", Value: coll.Name()}, {Key: "filter", Value: filter}}
+	if limit > 0 {
+		cmd = append(cmd, bson.E{Key: "limit", Value: limit})
+	}
+	return cmd
+}
+
+// aggregateCommand is the aggregate command coll.Aggregate sends, for
+// explaining.
+func aggregateCommand(coll *mongo.Collection, pipeline mongo.Pipeline, allowDiskUse bool) bson.D {
+	return bson.D{
+		{Key: "aggregate", Value: coll.Name()},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "allowDiskUse", Value: allowDiskUse},
+		{Key: "cursor", Value: bson.D{}},
+	}
+}
+
+// explainScenario runs the scenario's query under explain("executionStats").
+func explainScenario(ctx context.Context, env *runEnv, sc scenario) (*queryPlan, error) {
+	coll, cmd := sc.explain(env)
+	explain := bson.D{{Key: "explain", Value: cmd}, {Key: "verbosity", Value: "executionStats"}}
+	opts := options.RunCmd()
+	if sc.explainReadPref != nil {
+		opts.SetReadPreference(sc.explainReadPref)
+	}
+	raw, err := coll.Database().RunCommand(ctx, explain, opts).Raw()
+	if err != nil {
+		return nil, err
+	}
+	return parseExplain(raw), nil
+}
+
+// parseExplain reads the explain output of a find or aggregate. It copes with
+// the classic and slot-based engines, aggregations that run as a $cursor
+// stage plus pipeline stages, and mongos output with one plan per shard.
+func parseExplain(doc bson.Raw) *queryPlan {
+	p := &queryPlan{}
+	readExplainPlan(p, doc)
+
+	if stages, ok := doc.Lookup("stages").ArrayOK(); ok {
+		values, _ := stages.Values()
+		for _, v := range values {
+			stage, ok := v.DocumentOK()
+			if !ok {
+				continue
+			}
+			elems, _ := stage.Elements()
+			for _, e := range elems {
+				if !strings.HasPrefix(e.Key(), "$") {
+					continue
+				}
+				if e.Key() == "$cursor" {
+					readExplainPlan(p, e.Value().Document())
+					continue
+				}
+				p.Stages = append(p.Stages, planStage{
+					Stage:     e.Key(),
+					NReturned: rawInt(stage, "nReturned"),
+					TimeMs:    rawInt(stage, "executionTimeMillisEstimate"),
+				})
+			}
+		}
+	}
+	return p
+}
+
+func readExplainPlan(p *queryPlan, doc bson.Raw) {
+	if winning, ok := doc.Lookup("queryPlanner", "winningPlan").DocumentOK(); ok {
+		p.WinningPlan = describePlan(winning)
+	}
+	stats, ok := doc.Lookup("executionStats").DocumentOK()
+	if !ok {
+		return
+	}
+	p.NReturned = rawInt(stats, "nReturned")
+	p.KeysExamined = rawInt(stats, "totalKeysExamined")
+	p.DocsExamined = rawInt(stats, "totalDocsExamined")
+	p.ExecutionTimeMs = rawInt(stats, "executionTimeMillis")
+	if root, ok := stats.Lookup("executionStages").DocumentOK(); ok {
+		p.Stages = append(flattenStages(root), p.Stages...)
+	}
+}
+
+// describePlan renders a winning plan as its stages from the root down.
+// Stages with several inputs, and mongos plans, list them in brackets.
+func describePlan(plan bson.Raw) string {
+	if qp, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		return describePlan(qp)
+	}
+	stage := rawString(plan, "stage")
+	if index, ok := plan.Lookup("indexName").StringValueOK(); ok {
+		stage += "(" + index + ")"
+	}
+
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		return stage + " > " + describePlan(input)
+	}
+	var inputs []string
+	if arr, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := arr.Values()
+		for _, v := range values {
+			if d, ok := v.DocumentOK(); ok {
+				inputs = append(inputs, describePlan(d))
+			}
+		}
+	}
+	if arr, ok := plan.Lookup("shards").ArrayOK(); ok {
+		values, _ := arr.Values()
+		for _, v := range values {
+			shard, ok := v.DocumentOK()
+			if !ok {
+				continue
+			}
+			if winning, ok := shard.Lookup("winningPlan").DocumentOK(); ok {
+				inputs = append(inputs, rawString(shard, "shardName")+": "+describePlan(winning))
+			}
+		}
+	}
+	if len(inputs) > 0 {
+		return fmt.Sprintf("%s[%s]", stage, strings.Join(inputs, ", "))
+	}
+	return stage
+}
+
+// flattenStages lists an executionStages tree depth first.
+func flattenStages(stage bson.Raw) []planStage {
+	stages := []planStage{{
+		Stage:        rawString(stage, "stage"),
+		NReturned:    rawInt(stage, "nReturned"),
+		TimeMs:       rawInt(stage, "executionTimeMillisEstimate"),
+		KeysExamined: rawInt(stage, "keysExamined"),
+		DocsExamined: rawInt(stage, "docsExamined"),
+	}}
+	if input, ok := stage.Lookup("inputStage").DocumentOK(); ok {
+		stages = append(stages, flattenStages(input)...)
+	}
+	for _, key := range []string{"inputStages", "shards"} {
+		arr, ok := stage.Lookup(key).ArrayOK()
+		if !ok {
+			continue
+		}
+		values, _ := arr.Values()
+		for _, v := range values {
+			d, ok := v.DocumentOK()
+			if !ok {
+				continue
+			}
+			if shardStages, ok := d.Lookup("executionStages").DocumentOK(); ok {
+				d = shardStages
+			}
+			stages = append(stages, flattenStages(d)...)
+		}
+	}
+	return stages
+}
+
+func rawString(doc bson.Raw, key string) string {
+	s, _ := doc.Lookup(key).StringValueOK()
+	return s
+}
+
+func rawInt(doc bson.Raw, key string) int64 {
+	n, _ := doc.Lookup(key).AsInt64OK()
+	return n
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func mustRaw(t *testing.T, doc bson.D) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestParseExplainFind(t *testing.T) {
+	ixscan := bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "indexName", Value: "updated_1"}}
+	doc := mustRaw(t, bson.D{
+		{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+			{Key: "stage", Value: "LIMIT"},
+			{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "FETCH"}, {Key: "inputStage", Value: ixscan}}},
+		}}}},
+		{Key: "executionStats", Value: bson.D{
+			{Key: "nReturned", Value: int32(1)},
+			{Key: "executionTimeMillis", Value: int32(2)},
+			{Key: "totalKeysExamined", Value: int32(1)},
+			{Key: "totalDocsExamined", Value: int64(1)},
+			{Key: "executionStages", Value: bson.D{
+				{Key: "stage", Value: "LIMIT"},
+				{Key: "nReturned", Value: int32(1)},
+				{Key: "inputStage", Value: bson.D{
+					{Key: "stage", Value: "FETCH"},
+					{Key: "docsExamined", Value: int32(1)},
+					{Key: "inputStage", Value: bson.D{{Key: "stage", Value: "IXSCAN"}, {Key: "keysExamined", Value: int32(1)}}},
+				}},
+			}},
+		}},
+	})
+
+	p := parseExplain(doc)
+	if p.WinningPlan != "LIMIT > FETCH > IXSCAN(updated_1)" {
+		t.Errorf("winning plan %q", p.WinningPlan)
+	}
+	if p.NReturned != 1 || p.KeysExamined != 1 || p.DocsExamined != 1 || p.ExecutionTimeMs != 2 {
+		t.Errorf("unexpected counters %+v", p)
+	}
+	if len(p.Stages) != 3 || p.Stages[1].DocsExamined != 1 || p.Stages[2].KeysExamined != 1 {
+		t.Errorf("unexpected stages %+v", p.Stages)
+	}
+}
+
+func TestParseExplainAggregate(t *testing.T) {
+	doc := mustRaw(t, bson.D{{Key: "stages", Value: bson.A{
+		bson.D{
+			{Key: "$cursor", Value: bson.D{
+				{Key: "queryPlanner", Value: bson.D{{Key: "winningPlan", Value: bson.D{
+					{Key: "queryPlan", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+				}}}},
+				{Key: "executionStats", Value: bson.D{
+					{Key: "nReturned", Value: int32(100)},
+					{Key: "totalDocsExamined", Value: int32(100)},
+					{Key: "executionStages", Value: bson.D{{Key: "stage", Value: "COLLSCAN"}}},
+				}},
+			}},
+			{Key: "nReturned", Value: int64(100)},
+		},
+		bson.D{
+			{Key: "$group", Value: bson.D{{Key: "_id", Value: "$category"}}},
+			{Key: "nReturned", Value: int64(8)},
+			{Key: "executionTimeMillisEstimate", Value: int64(3)},
+		},
+	}}})
+
+	p := parseExplain(doc)
+	if p.WinningPlan != "COLLSCAN" || p.DocsExamined != 100 {
+		t.Errorf("unexpected plan %+v", p)
+	}
+	if len(p.Stages) != 2 || p.Stages[1] != (planStage{Stage: "$group", NReturned: 8, TimeMs: 3}) {
+		t.Errorf("unexpected stages %+v", p.Stages)
+	}
+}
@@ -2,7 +2,10 @@ module mongoVersionSpeedTest
 
 go 1.24.4
 
-require go.mongodb.org/mongo-driver/v2 v2.2.2
+require (
+	go.mongodb.org/mongo-driver/v2 v2.2.2
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang/snappy v1.0.0 // indirect
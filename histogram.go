@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogram is an HDR-style latency histogram. Values below subBucketCount
+// are counted exactly; above that every power-of-two range is split into
+// subBucketHalf linear buckets, which keeps three significant digits at any
+// magnitude.
+type histogram struct {
+	counts []uint64
+	total  uint64
+	min    int64
+	max    int64
+	sum    float64
+}
+
+const (
+	subBucketBits  = 11
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+)
+
+// latencySummary is the percentile view of a histogram that goes into results.
+// All values are nanoseconds.
+type latencySummary struct {
+	Min  int64   `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  int64   `json:"p50"`
+	P90  int64   `json:"p90"`
+	P99  int64   `json:"p99"`
+	P999 int64   `json:"p999"`
+	Max  int64   `json:"max"`
+}
+
+func newHistogram() *histogram {
+	return &histogram{min: math.MaxInt64}
+}
+
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	return shift*subBucketHalf + int(v>>shift)
+}
+
+// bucketRange returns the lowest value counted by bucket idx and the bucket width.
+func bucketRange(idx int) (lower, width int64) {
+	if idx < subBucketCount {
+		return int64(idx), 1
+	}
+	shift := idx/subBucketHalf - 1
+	sub := idx - shift*subBucketHalf
+	return int64(sub) << shift, 1 << shift
+}
+
+func (h *histogram) record(v int64) {
+	if v < 0 {
+		v = 0
+	}
+	idx := bucketIndex(v)
+	if idx >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, idx+1-len(h.counts))...)
+	}
+	h.counts[idx]++
+	h.total++
+	h.sum += float64(v)
+	h.min = min(h.min, v)
+	h.max = max(h.max, v)
+}
+
+func (h *histogram) recordDuration(d time.Duration) {
+	h.record(d.Nanoseconds())
+}
+
+// merge adds every value recorded in other to h.
+func (h *histogram) merge(other *histogram) {
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(other.counts)-len(h.counts))...)
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+// percentile returns the value at or below which q percent of the recorded
+// values fall, rounded up to the top of its bucket.
+func (h *histogram) percentile(q float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q / 100 * float64(h.total)))
+	rank = max(rank, 1)
+
+	var seen uint64
+	for idx, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			lower, width := bucketRange(idx)
+			return min(lower+width-1, h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *histogram) mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return h.sum / float64(h.total)
+}
+
+func (h *histogram) summary() *latencySummary {
+	if h.total == 0 {
+		return nil
+	}
+	return &latencySummary{
+		Min:  h.min,
+		Mean: h.mean(),
+		P50:  h.percentile(50),
+		P90:  h.percentile(90),
+		P99:  h.percentile(99),
+		P999: h.percentile(99.9),
+		Max:  h.max,
+	}
+}
+
+// writePercentiles writes the histogram as an HdrHistogram percentile
+// distribution (.hgrm), which the HdrHistogram plotter and most tooling
+// around it can read. Values are in microseconds.
+func (h *histogram) writePercentiles(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)"); err != nil {
+		return err
+	}
+
+	var seen uint64
+	for idx, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		seen += c
+		lower, width := bucketRange(idx)
+		value := float64(min(lower+width-1, h.max)) / 1e3
+		q := float64(seen) / float64(h.total)
+		if q < 1 {
+			_, err := fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", value, q, seen, 1/(1-q))
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err := fmt.Fprintf(w, "%12.3f %2.12f %10d\n", value, q, seen)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n#[Max     = %12.3f, Total count    = %12d]\n",
+		h.mean()/1e3, h.stddev()/1e3, float64(h.max)/1e3, h.total)
+	return err
+}
+
+func (h *histogram) stddev() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	mean := h.mean()
+	var sq float64
+	for idx, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		lower, width := bucketRange(idx)
+		d := float64(lower) + float64(width-1)/2 - mean
+		sq += d * d * float64(c)
+	}
+	return math.Sqrt(sq / float64(h.total))
+}
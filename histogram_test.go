@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBucketRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 123456789, 1 << 40} {
+		idx := bucketIndex(v)
+		lower, width := bucketRange(idx)
+		if v < lower || v >= lower+width {
+			t.Errorf("value %d landed in bucket %d covering [%d, %d)", v, idx, lower, lower+width)
+		}
+		if width > 1 && float64(width)/float64(lower) > 1.0/subBucketHalf {
+			t.Errorf("bucket %d for %d is wider than the configured precision", idx, v)
+		}
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := newHistogram()
+	for v := int64(1); v <= 1000; v++ {
+		h.record(v * 1000)
+	}
+
+	tests := []struct {
+		q    float64
+		want int64
+	}{
+		{50, 500000},
+		{90, 900000},
+		{99, 990000},
+		{100, 1000000},
+	}
+	for _, tt := range tests {
+		got := h.percentile(tt.q)
+		if diff := float64(got-tt.want) / float64(tt.want); diff < 0 || diff > 0.001 {
+			t.Errorf("p%v = %d, want %d within 0.1%%", tt.q, got, tt.want)
+		}
+	}
+	if h.min != 1000 || h.max != 1000000 {
+		t.Errorf("min/max = %d/%d", h.min, h.max)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := newHistogram(), newHistogram()
+	a.record(10)
+	b.record(5000000)
+	a.merge(b)
+	if a.total != 2 || a.min != 10 || a.max != 5000000 {
+		t.Errorf("merged histogram total=%d min=%d max=%d", a.total, a.min, a.max)
+	}
+}
+
+func TestWritePercentiles(t *testing.T) {
+	h := newHistogram()
+	h.record(1000)
+	h.record(2000)
+	var buf bytes.Buffer
+	if err := h.writePercentiles(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Total count    =            2") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
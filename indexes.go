@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// indexSpec is a secondary index the runner can create by name.
+type indexSpec struct {
+	name    string
+	keys    bson.D
+	unique  bool
+	partial bson.M
+	// ttl, when set, makes it a TTL index.
+	ttl time.Duration
+}
+
+func (spec indexSpec) model() mongo.IndexModel {
+	opts := options.Index().SetName(spec.name)
+	if spec.unique {
+		opts.SetUnique(true)
+	}
+	if spec.partial != nil {
+		opts.SetPartialFilterExpression(spec.partial)
+	}
+	if spec.ttl > 0 {
+		opts.SetExpireAfterSeconds(int32(spec.ttl / time.Second))
+	}
+	return mongo.IndexModel{Keys: spec.keys, Options: opts}
+}
+
+// updatedIndex is the index the original suite creates in CreateIndex.
+var updatedIndex = indexSpec{name: "updated_1", keys: bson.D{{Key: "updated", Value: 1}}}
+
+// Values the index suite's documents draw from, so queries can pick ones that
+// match.
+var (
+	indexCategories = []string{"archive", "audio", "backup", "document", "image", "log", "source", "video"}
+	indexTags       = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta", "eta", "theta", "iota", "kappa", "lambda", "mu"}
+	indexColors     = []string{"red", "green", "blue", "cyan", "magenta", "yellow", "black", "white"}
+	indexWords      = []string{"nightly", "backup", "quarterly", "report", "draft", "final", "invoice", "scan"}
+)
+
+// indexDocTemplate describes the documents of the index suite. It has a field
+// for every index kind, whatever -docgen says about the main collection.
+func indexDocTemplate(seed uint64) *docTemplate {
+	descriptions := make([]string, 0, len(indexWords)*len(indexWords))
+	for _, a := range indexWords {
+		for _, b := range indexWords {
+			descriptions = append(descriptions, a+" "+b)
+		}
+	}
+	return &docTemplate{
+		Seed:     seed,
+		IDs:      idsSequential,
+		IDPrefix: "idx",
+		Fields: []fieldSpec{
+			{Name: "fileName", Type: "string", Pattern: "file%d.dat"},
+			{Name: "editDate", Type: "date", DaysBack: 365},
+			{Name: "count", Type: "int", Sequence: true},
+			{Name: "category", Type: "string", Values: indexCategories},
+			{Name: "tags", Type: "array", Items: &fieldSpec{Type: "string", Values: indexTags}, MinItems: 1, MaxItems: 5},
+			{Name: "description", Type: "string", Values: descriptions},
+			{Name: "attrs", Type: "object", Fields: []fieldSpec{
+				{Name: "color", Type: "string", Values: indexColors},
+				{Name: "size", Type: "int", Min: 1, Max: 100},
+			}},
+		},
+	}
+}
+
+// indexKind is one index strategy of the index suite, with a query that
+// uses it. The "none" kind has neither and is the baseline for writes.
+type indexKind struct {
+	name  string
+	index *indexSpec
+	query func(env *runEnv, i int) bson.M
+}
+
+var indexKinds = []indexKind{
+	{name: "none"},
+	{
+		name:  "single",
+		index: &indexSpec{name: "count_1", keys: bson.D{{Key: "count", Value: 1}}},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"count": bson.M{"$gte": i % env.cfg.indexDocs}}
+		},
+	},
+	{
+		name:  "compound",
+		index: &indexSpec{name: "category_1_count_-1", keys: bson.D{{Key: "category", Value: 1}, {Key: "count", Value: -1}}},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"category": pick(indexCategories, i), "count": bson.M{"$lt": i % env.cfg.indexDocs}}
+		},
+	},
+	{
+		name:  "multikey",
+		index: &indexSpec{name: "tags_1", keys: bson.D{{Key: "tags", Value: 1}}},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"tags": pick(indexTags, i)}
+		},
+	},
+	{
+		name:  "hashed",
+		index: &indexSpec{name: "fileName_hashed", keys: bson.D{{Key: "fileName", Value: "hashed"}}},
+		query: fileNameQuery,
+	},
+	{
+		name:  "text",
+		index: &indexSpec{name: "description_text", keys: bson.D{{Key: "description", Value: "text"}}},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"$text": bson.M{"$search": pick(indexWords, i)}}
+		},
+	},
+	{
+		name:  "wildcard",
+		index: &indexSpec{name: "attrs.$**_1", keys: bson.D{{Key: "attrs.$**", Value: 1}}},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"attrs.color": pick(indexColors, i)}
+		},
+	},
+	{
+		name: "partial",
+		index: &indexSpec{
+			name:    "count_1_archive",
+			keys:    bson.D{{Key: "count", Value: 1}},
+			partial: bson.M{"category": "archive"},
+		},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"category": "archive", "count": bson.M{"$gte": i % env.cfg.indexDocs}}
+		},
+	},
+	{
+		name: "ttl",
+		// Long enough that nothing expires during a run; the cost measured is
+		// the index plus the TTL monitor scanning it.
+		index: &indexSpec{name: "editDate_1", keys: bson.D{{Key: "editDate", Value: 1}}, ttl: 10 * 365 * 24 * time.Hour},
+		query: func(env *runEnv, i int) bson.M {
+			return bson.M{"editDate": bson.M{"$gte": docEpoch.Add(-time.Duration(i%365) * 24 * time.Hour)}}
+		},
+	},
+	{
+		name:  "unique",
+		index: &indexSpec{name: "fileName_1", keys: bson.D{{Key: "fileName", Value: 1}}, unique: true},
+		query: fileNameQuery,
+	},
+}
+
+func fileNameQuery(env *runEnv, i int) bson.M {
+	return bson.M{"fileName": fmt.Sprintf("file%d.dat", i%env.cfg.indexDocs+1)}
+}
+
+func pick(values []string, i int) string {
+	return values[i%len(values)]
+}
+
+// specs is the kind's index as a dataset index list.
+func (k indexKind) specs() []indexSpec {
+	if k.index == nil {
+		return nil
+	}
+	return []indexSpec{*k.index}
+}
+
+func indexKindNames() []string {
+	names := make([]string, len(indexKinds))
+	for i, k := range indexKinds {
+		names[i] = k.name
+	}
+	return names
+}
+
+func lookupIndexKind(name string) (indexKind, bool) {
+	for _, k := range indexKinds {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return indexKind{}, false
+}
+
+// indexQueryLimit caps how many documents an IndexedQuery iteration reads.
+const indexQueryLimit = 20
+
+// indexVariants returns a variants function making one scenario per -indexes
+// kind. Kinds without an index are left out unless withNone is set, and kinds
+// without a query when needsQuery is.
+func indexVariants(name string, withNone, needsQuery bool, build func(k indexKind) scenario) func(cfg *runConfig) []scenario {
+	return func(cfg *runConfig) []scenario {
+		var variants []scenario
+		for _, kindName := range cfg.indexKinds {
+			k, _ := lookupIndexKind(kindName)
+			if (k.index == nil && !withNone) || (k.query == nil && needsQuery) {
+				continue
+			}
+			sc := build(k)
+			sc.name = name
+			sc.params = map[string]string{"index": k.name}
+			variants = append(variants, sc)
+		}
+		return variants
+	}
+}
+
+// The index suite. Each scenario works on its own collection of
+// -index-docs documents from indexDocTemplate with one index kind in place.
+var (
+	// indexBuildVariants times building each index on a full collection.
+	indexBuildVariants = indexVariants("IndexBuild", false, false, func(k indexKind) scenario {
+		return scenario{
+			once:    true,
+			dataset: dataset{collection: collectionSeeded, docs: indexSuiteDocs},
+			op: func(ctx context.Context, env *runEnv, i int) error {
+				return createIndex(ctx, env.indexColl(), *k.index)
+			},
+		}
+	})
+
+	// indexedInsertVariants measures inserts into a collection that has the
+	// index.
+	indexedInsertVariants = indexVariants("IndexedInsert", true, false, func(k indexKind) scenario {
+		return scenario{
+			concurrent: true,
+			dataset:    dataset{collection: collectionEmpty, docs: indexSuiteDocs, indexes: k.specs()},
+			op: func(ctx context.Context, env *runEnv, i int) error {
+				return insertOne(ctx, env.indexColl(), env.cfg.indexGen.doc(i+1))
+			},
+			bytesPerOp: func(env *runEnv) float64 {
+				return env.cfg.indexGen.avgDocSize()
+			},
+		}
+	})
+
+	// indexedUpdateVariants measures updates that change every indexed
+	// field, so each kind pays for maintaining its index.
+	indexedUpdateVariants = indexVariants("IndexedUpdate", true, false, func(k indexKind) scenario {
+		return scenario{
+			concurrent: true,
+			dataset:    dataset{collection: collectionSeeded, docs: indexSuiteDocs, indexes: k.specs()},
+			op: func(ctx context.Context, env *runEnv, i int) error {
+				doc := i%env.cfg.indexDocs + 1
+				return updateIndexedFields(ctx, env.indexColl(), env.cfg.indexGen.id(doc), doc, i)
+			},
+			// The updates leave documents other scenarios don't expect, so
+			// the next one reseeds.
+			teardown: func(ctx context.Context, env *runEnv) error {
+				return dropCollection(ctx, env.indexColl())
+			},
+		}
+	})
+
+	// indexedQueryVariants measures the query each index kind serves.
+	indexedQueryVariants = indexVariants("IndexedQuery", false, true, func(k indexKind) scenario {
+		return scenario{
+			concurrent: true,
+			dataset:    dataset{collection: collectionSeeded, docs: indexSuiteDocs, indexes: k.specs()},
+			op: func(ctx context.Context, env *runEnv, i int) error {
+				return findFirst(ctx, env.indexColl(), k.query(env, i), indexQueryLimit)
+			},
+			explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+				return env.indexColl(), findCommand(env.indexColl(), k.query(env, 0), indexQueryLimit)
+			},
+		}
+	})
+)
@@ -1,12 +1,63 @@
 package main
 
 import (
-	"go.mongodb.org/mongo-driver/v2/mongo"
-)
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
 
-var db *mongo.Database
-var coll *mongo.Collection
+	"mongoVersionSpeedTest/workload"
+)
 
 func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runCommand(os.Args[2:])
+	case "compare":
+		err = compareCommand(os.Args[2:])
+	case "report":
+		err = reportCommand(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	default:
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: mongoVersionSpeedTest <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  run       benchmark scenarios against the configured targets")
+	fmt.Fprintln(w, "  compare   compare result files across server versions")
+	fmt.Fprintln(w, "  report    render an HTML and Markdown report from result files")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Scenarios:")
+	for _, sc := range scenarios {
+		fmt.Fprintln(w, "  "+sc.name)
+	}
+	if registered := workload.All(); len(registered) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Registered scenarios:")
+		for _, sc := range registered {
+			fmt.Fprintln(w, "  "+fromWorkload(sc).label())
+		}
+	}
 }
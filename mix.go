@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// opMix is a workload file for -workload: a weighted mix of operations run
+// together against the main collection, e.g. 70% FindOneById, 20% UpdateOne
+// and 10% InsertOne over 1M documents for 60s at 8 workers. See
+// mix.example.yaml.
+type opMix struct {
+	// Name labels the mix's results; it defaults to the file name.
+	Name string `yaml:"name"`
+	// Docs is how many documents the collection is seeded with (default
+	// -docs).
+	Docs int `yaml:"docs"`
+	// Duration runs the mix for that long; without it the mix runs -n
+	// operations.
+	Duration time.Duration `yaml:"duration"`
+	// Workers is the worker count (default the -workers sweep).
+	Workers int `yaml:"workers"`
+	// Seed picks the operation and document of each iteration (default the
+	// document generator's), so the same file replays the same sequence.
+	Seed uint64  `yaml:"seed"`
+	Ops  []mixOp `yaml:"ops"`
+}
+
+type mixOp struct {
+	Op     string `yaml:"op"`
+	Weight int    `yaml:"weight"`
+}
+
+// mixOps are the operations a mix can use. doc is a random document of the
+// seeded collection and fresh a document number no other iteration inserts.
+var mixOps = map[string]func(ctx context.Context, env *runEnv, doc, fresh int) error{
+	"InsertOne": func(ctx context.Context, env *runEnv, doc, fresh int) error {
+		return insertOne(ctx, env.coll, env.gen.doc(fresh))
+	},
+	"UpdateOne": func(ctx context.Context, env *runEnv, doc, fresh int) error {
+		return updateOne(ctx, env.coll, env.gen.id(doc))
+	},
+	"FindOne": func(ctx context.Context, env *runEnv, doc, fresh int) error {
+		return findOne(ctx, env.coll)
+	},
+	"FindOneById": func(ctx context.Context, env *runEnv, doc, fresh int) error {
+		return findOneById(ctx, env.coll, env.gen.id(doc), nil)
+	},
+	"FindOneByIdWithDeserialization": func(ctx context.Context, env *runEnv, doc, fresh int) error {
+		return findOneById(ctx, env.coll, env.gen.id(doc), env.decodeTarget())
+	},
+}
+
+// mixStream separates the mix's random sequence from the generator's.
+const mixStream = 0x6d6978
+
+func loadOpMix(path string) (*opMix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &opMix{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *opMix) validate() error {
+	if len(m.Ops) == 0 {
+		return errors.New("no ops")
+	}
+	for _, o := range m.Ops {
+		if _, ok := mixOps[o.Op]; !ok {
+			names := slices.Sorted(maps.Keys(mixOps))
+			return fmt.Errorf("unknown op %q, want one of %s", o.Op, strings.Join(names, ", "))
+		}
+		if o.Weight < 1 {
+			return fmt.Errorf("op %s: weight must be positive", o.Op)
+		}
+	}
+	if m.Docs < 0 || m.Duration < 0 || m.Workers < 0 {
+		return errors.New("docs, duration and workers can't be negative")
+	}
+	return nil
+}
+
+// pick returns the op and document iteration i uses.
+func (m *opMix) pick(i int) (mixOp, int) {
+	total := 0
+	for _, o := range m.Ops {
+		total += o.Weight
+	}
+	r := rand.New(rand.NewPCG(m.Seed^mixStream, uint64(i)))
+	w := r.IntN(total)
+	doc := r.IntN(m.Docs) + 1
+	for _, o := range m.Ops {
+		if w < o.Weight {
+			return o, doc
+		}
+		w -= o.Weight
+	}
+	panic("unreachable")
+}
+
+// scenario runs the mix as the Mix scenario labelled with its name. Inserted
+// documents are numbered after the seeded ones.
+func (m *opMix) scenario() scenario {
+	return scenario{
+		name:       "Mix",
+		params:     map[string]string{"mix": m.Name},
+		concurrent: true,
+		duration:   m.Duration,
+		dataset:    dataset{collection: collectionSeeded, docCount: m.Docs},
+		op: func(ctx context.Context, env *runEnv, i int) error {
+			o, doc := m.pick(i)
+			return mixOps[o.Op](ctx, env, doc, m.Docs+i+1)
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadExampleMix(t *testing.T) {
+	m, err := loadOpMix("mix.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "read-heavy" || m.Docs != 1000000 || m.Duration != time.Minute || m.Workers != 8 || len(m.Ops) != 3 {
+		t.Errorf("unexpected mix %+v", m)
+	}
+}
+
+func TestMixValidation(t *testing.T) {
+	for name, m := range map[string]opMix{
+		"no ops":       {},
+		"unknown op":   {Ops: []mixOp{{Op: "Explode", Weight: 1}}},
+		"zero weight":  {Ops: []mixOp{{Op: "FindOne"}}},
+		"negative doc": {Docs: -1, Ops: []mixOp{{Op: "FindOne", Weight: 1}}},
+	} {
+		if err := m.validate(); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+}
+
+func TestMixPicksByWeight(t *testing.T) {
+	m := &opMix{Docs: 100, Seed: 7, Ops: []mixOp{{Op: "FindOneById", Weight: 70}, {Op: "UpdateOne", Weight: 20}, {Op: "InsertOne", Weight: 10}}}
+	counts := map[string]int{}
+	const n = 100000
+	for i := range n {
+		o, doc := m.pick(i)
+		counts[o.Op]++
+		if doc < 1 || doc > m.Docs {
+			t.Fatalf("iteration %d picked document %d", i, doc)
+		}
+		if again, againDoc := m.pick(i); again != o || againDoc != doc {
+			t.Fatalf("iteration %d is not reproducible", i)
+		}
+	}
+	for _, o := range m.Ops {
+		share := float64(counts[o.Op]) / n * 100
+		if share < float64(o.Weight)-1 || share > float64(o.Weight)+1 {
+			t.Errorf("%s picked %.1f%% of the time, want %d%%", o.Op, share, o.Weight)
+		}
+	}
+}
+
+func TestWorkloadFlagReplacesDefaultScenarios(t *testing.T) {
+	cfg, err := parseRunFlags([]string{"-workload", "mix.example.yaml", "-workers", "1,2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 1 || planned[0].label() != "Mix[mix=read-heavy]" || planned[0].workers[0] != 8 || planned[0].duration != time.Minute {
+		t.Errorf("unexpected plan %+v", planned)
+	}
+}
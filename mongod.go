@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// How long a launched mongod gets to accept connections, become primary and
+// shut down.
+const (
+	mongodStartTimeout    = 60 * time.Second
+	mongodShutdownTimeout = 30 * time.Second
+)
+
+// mongodProcess is a mongod the tool started for a target with a Binary. It
+// runs on a free localhost port with its data in a temporary directory that
+// stop removes.
+type mongodProcess struct {
+	target Target
+	cmd    *exec.Cmd
+	dbPath string
+	port   int
+	exited chan struct{}
+	err    error
+}
+
+// mongodArgs are the command line arguments for t's mongod.
+func (t Target) mongodArgs(dbPath string, port int) []string {
+	args := []string{
+		"--dbpath", dbPath,
+		"--port", strconv.Itoa(port),
+		"--bind_ip", "127.0.0.1",
+		"--logpath", filepath.Join(dbPath, "mongod.log"),
+	}
+	if set := t.launchedReplicaSet(); set != "" {
+		args = append(args, "--replSet", set)
+	}
+	return append(args, t.MongodArgs...)
+}
+
+// launchedReplicaSet is the name of the single-member replica set a launched
+// mongod runs as, or "" for a standalone.
+func (t Target) launchedReplicaSet() string {
+	if t.ReplicaSet != "" {
+		return t.ReplicaSet
+	}
+	if t.Topology == topologyReplicaSet {
+		return "rs0"
+	}
+	return ""
+}
+
+// freePort asks the kernel for an unused localhost port.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// startMongod launches t's binary and waits until it accepts connections
+// and, as a replica set, has a writable primary.
+func startMongod(ctx context.Context, t Target) (*mongodProcess, error) {
+	if t.Topology == topologySharded {
+		return nil, fmt.Errorf("target %s: launching sharded clusters is not supported", t.Name)
+	}
+	dbPath, err := os.MkdirTemp("", "speedtest-"+t.Name+"-")
+	if err != nil {
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dbPath)
+		return nil, err
+	}
+
+	p := &mongodProcess{target: t, dbPath: dbPath, port: port, exited: make(chan struct{})}
+	p.cmd = exec.Command(t.Binary, t.mongodArgs(dbPath, port)...)
+	if err := p.cmd.Start(); err != nil {
+		os.RemoveAll(dbPath)
+		return nil, fmt.Errorf("target %s: %w", t.Name, err)
+	}
+	go func() {
+		p.err = p.cmd.Wait()
+		close(p.exited)
+	}()
+	log.Printf("Started %s for %s on port %d, dbpath %s", t.Binary, t.Name, port, dbPath)
+
+	if err := p.waitReady(ctx); err != nil {
+		p.stop()
+		return nil, fmt.Errorf("target %s: %w", t.Name, err)
+	}
+	return p, nil
+}
+
+// uri connects straight to the launched mongod.
+func (p *mongodProcess) uri() string {
+	return fmt.Sprintf("mongodb://127.0.0.1:%d/?directConnection=true", p.port)
+}
+
+func (p *mongodProcess) waitReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, mongodStartTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(options.Client().ApplyURI(p.uri()))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(context.Background())
+
+	admin := client.Database("admin")
+	initiated := false
+	for {
+		select {
+		case <-p.exited:
+			return fmt.Errorf("mongod exited: %v%s", p.err, p.logTail())
+		case <-ctx.Done():
+			return fmt.Errorf("mongod not ready after %s%s", mongodStartTimeout, p.logTail())
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			continue
+		}
+		set := p.target.launchedReplicaSet()
+		if set == "" || hello.IsWritablePrimary {
+			return nil
+		}
+		if !initiated {
+			cfg := bson.D{
+				{Key: "_id", Value: set},
+				{Key: "members", Value: bson.A{bson.D{{Key: "_id", Value: 0}, {Key: "host", Value: fmt.Sprintf("127.0.0.1:%d", p.port)}}}},
+			}
+			if err := admin.RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: cfg}}).Err(); err != nil {
+				return fmt.Errorf("replSetInitiate: %w", err)
+			}
+			initiated = true
+		}
+	}
+}
+
+// logTail returns the end of mongod's log to explain a failed start.
+func (p *mongodProcess) logTail() string {
+	data, err := os.ReadFile(filepath.Join(p.dbPath, "mongod.log"))
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	return "\n" + string(bytes.Join(lines[max(0, len(lines)-5):], []byte("\n")))
+}
+
+// stop shuts mongod down cleanly, killing it if it doesn't exit in time, and
+// removes its data.
+func (p *mongodProcess) stop() error {
+	var errs []error
+	select {
+	case <-p.exited:
+	default:
+		if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+			errs = append(errs, err)
+		}
+		select {
+		case <-p.exited:
+		case <-time.After(mongodShutdownTimeout):
+			log.Printf("mongod for %s did not shut down in %s, killing it", p.target.Name, mongodShutdownTimeout)
+			errs = append(errs, p.cmd.Process.Kill())
+			<-p.exited
+		}
+	}
+	errs = append(errs, os.RemoveAll(p.dbPath))
+	log.Printf("Stopped mongod for %s", p.target.Name)
+	return errors.Join(errs...)
+}
+
+// launchTarget starts a mongod for t when it has a Binary and returns the
+// target pointed at it, with a function that stops it again. Other targets
+// are returned unchanged.
+func launchTarget(ctx context.Context, t Target) (Target, func(), error) {
+	if t.Binary == "" {
+		return t, func() {}, nil
+	}
+	p, err := startMongod(ctx, t)
+	if err != nil {
+		return t, nil, err
+	}
+	t.URI = p.uri()
+	t.Hosts = nil
+	return t, func() {
+		if err := p.stop(); err != nil {
+			log.Printf("Error stopping mongod for %s: %v", t.Name, err)
+		}
+	}, nil
+}
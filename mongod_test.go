@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMongodArgs(t *testing.T) {
+	target := Target{Name: "rs", Topology: topologyReplicaSet, MongodArgs: []string{"--wiredTigerCacheSizeGB", "1"}}
+	args := target.mongodArgs("/tmp/db", 27100)
+	joined := strings.Join(args, " ")
+	for _, want := range []string{"--dbpath /tmp/db", "--port 27100", "--replSet rs0", "--wiredTigerCacheSizeGB 1"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("args %q lack %q", joined, want)
+		}
+	}
+	if args := (Target{Name: "s"}).mongodArgs("/tmp/db", 27100); slices.Contains(args, "--replSet") {
+		t.Errorf("standalone started as a replica set: %q", args)
+	}
+}
+
+func TestStartMongodCleansUpWhenBinaryExits(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	bin := filepath.Join(t.TempDir(), "mongod")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho 'not really mongod' >&2\nexit 3\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := startMongod(context.Background(), Target{Name: "fake", Binary: bin})
+	if err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("got error %v, want an exit error", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Errorf("dbpath left behind: %v", entries)
+	}
+}
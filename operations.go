@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Operations shared by the go test benchmarks and the run command.
+
+const gridFSFileName = "fileForInsert.txt"
+
+type myFile struct {
+	Id       string    `bson:"_id"`
+	FileName string    `bson:"fileName"`
+	EditDate time.Time `bson:"editDate"`
+	Count    int       `bson:"count"`
+	Updated  bool      `bson:"updated,omitempty"`
+}
+
+func insertOne(ctx context.Context, coll *mongo.Collection, doc any) error {
+	_, err := coll.InsertOne(ctx, doc)
+	return err
+}
+
+func insertMany(ctx context.Context, coll *mongo.Collection, docs []any, ordered bool) error {
+	_, err := coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(ordered))
+	return err
+}
+
+func updateOne(ctx context.Context, coll *mongo.Collection, id any) error {
+	filter := bson.M{"_id": id}
+	update := bson.M{"$set": bson.M{"updated": true}}
+
+	_, err := coll.UpdateOne(ctx, filter, update)
+	return err
+}
+
+func updateMany(ctx context.Context, coll *mongo.Collection) error {
+	filter := bson.M{}
+	update := bson.M{"$set": bson.M{"updated": true}}
+
+	_, err := coll.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func deleteOne(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.DeleteOne(ctx, bson.M{"updated": true})
+	return err
+}
+
+func deleteMany(ctx context.Context, coll *mongo.Collection) error {
+	_, err := coll.DeleteMany(ctx, bson.M{})
+	return err
+}
+
+func dropCollection(ctx context.Context, coll *mongo.Collection) error {
+	return coll.Drop(ctx)
+}
+
+func createIndex(ctx context.Context, coll *mongo.Collection, spec indexSpec) error {
+	_, err := coll.Indexes().CreateOne(ctx, spec.model())
+	return err
+}
+
+func findOne(ctx context.Context, coll *mongo.Collection) error {
+	return coll.FindOne(ctx, bson.M{}).Err()
+}
+
+// findOneById finds document id and, unless into is nil, decodes it.
+func findOneById(ctx context.Context, coll *mongo.Collection, id any, into any) error {
+	result := coll.FindOne(ctx, bson.M{"_id": id})
+	if into == nil {
+		return result.Err()
+	}
+	return result.Decode(into)
+}
+
+// findManyUsingIndex reads the updated documents and, unless newTarget is
+// nil, decodes each into a fresh value from newTarget.
+func findManyUsingIndex(ctx context.Context, coll *mongo.Collection, newTarget func() any) error {
+	filter := bson.M{"updated": true}
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	if newTarget == nil {
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		if err := cursor.Decode(newTarget()); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func findAll(ctx context.Context, coll *mongo.Collection) error {
+	cursor, err := coll.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// findFirst reads the first n documents matching filter.
+func findFirst(ctx context.Context, coll *mongo.Collection, filter any, n int64) error {
+	cursor, err := coll.Find(ctx, filter, options.Find().SetLimit(n))
+	if err != nil {
+		return err
+	}
+	for cursor.Next(ctx) {
+	}
+	if err := cursor.Err(); err != nil {
+		cursor.Close(ctx)
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// aggregate runs pipeline and reads every result, decoding them only when
+// decode is set.
+func aggregate(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, allowDiskUse, decode bool) error {
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(allowDiskUse))
+	if err != nil {
+		return err
+	}
+	if decode {
+		var docs []bson.M
+		return cursor.All(ctx, &docs)
+	}
+	for cursor.Next(ctx) {
+	}
+	if err := cursor.Err(); err != nil {
+		cursor.Close(ctx)
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// updateIndexedFields changes every field the index suite indexes in
+// document doc; iteration i keeps the new fileName unique.
+func updateIndexedFields(ctx context.Context, coll *mongo.Collection, id any, doc, i int) error {
+	update := bson.M{
+		"$set": bson.M{
+			"fileName":    fmt.Sprintf("file%d.%d.dat", doc, i),
+			"category":    pick(indexCategories, i),
+			"tags":        bson.A{pick(indexTags, i), pick(indexTags, i+1)},
+			"description": pick(indexWords, i) + " " + pick(indexWords, i+3),
+			"attrs.color": pick(indexColors, i),
+			"editDate":    time.Now(),
+		},
+		"$inc": bson.M{"count": 1},
+	}
+	_, err := coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// shardCollection shards coll on a hashed _id, which spreads documents
+// evenly over the shards.
+func shardCollection(ctx context.Context, coll *mongo.Collection) error {
+	db := coll.Database()
+	admin := db.Client().Database("admin")
+	if err := admin.RunCommand(ctx, bson.D{{Key: "enableSharding", Value: db.Name()}}).Err(); err != nil {
+		return err
+	}
+	cmd := bson.D{
+		{Key: "shardCollection", Value: db.Name() + "." + coll.Name()},
+		{Key: "key", Value: bson.D{{Key: "_id", Value: "hashed"}}},
+	}
+	return admin.RunCommand(ctx, cmd).Err()
+}
+
+func gridFSUploadFromStream(ctx context.Context, db *mongo.Database, path string) error {
+	bucket := db.GridFSBucket()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	uploadOpts := options.GridFSUpload().SetMetadata(bson.D{{Key: "metadata tag", Value: "first"}})
+	_, err = bucket.UploadFromStream(ctx, gridFSFileName, file, uploadOpts)
+	return err
+}
+
+func gridFSOpenUploadStream(ctx context.Context, db *mongo.Database, path string) error {
+	bucket := db.GridFSBucket()
+
+	fileContent, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Defines options that specify configuration information for files
+	// uploaded to the bucket
+	uploadOpts := options.GridFSUpload().SetChunkSizeBytes(200000)
+	// Writes a file to an output stream
+	uploadStream, err := bucket.OpenUploadStream(ctx, gridFSFileName, uploadOpts)
+	if err != nil {
+		return err
+	}
+	if _, err = uploadStream.Write(fileContent); err != nil {
+		uploadStream.Abort()
+		return err
+	}
+	// Calls the Close() method to write file metadata
+	return uploadStream.Close()
+}
+
+func gridFSDownloadToStream(ctx context.Context, db *mongo.Database) error {
+	bucket := db.GridFSBucket()
+
+	fileBuffer := bytes.NewBuffer(nil)
+	_, err := bucket.DownloadToStreamByName(ctx, gridFSFileName, fileBuffer)
+	return err
+}
+
+func gridFSOpenDownloadStream(ctx context.Context, db *mongo.Database) error {
+	bucket := db.GridFSBucket()
+
+	downloadStream, err := bucket.OpenDownloadStreamByName(ctx, gridFSFileName)
+	if err != nil {
+		return err
+	}
+	defer downloadStream.Close()
+
+	fileBytes := make([]byte, 1024)
+	if _, err := downloadStream.Read(fileBytes); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func gridFSDrop(ctx context.Context, db *mongo.Database) error {
+	return db.GridFSBucket().Drop(ctx)
+}
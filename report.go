@@ -0,0 +1,549 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// report is the data behind the HTML and Markdown reports.
+type report struct {
+	Title        string
+	Generated    time.Time
+	Baseline     string
+	Alpha        float64
+	Sources      []string
+	Versions     []string
+	Scenarios    []reportScenario
+	Environments []reportEnvironment
+}
+
+type reportScenario struct {
+	Name         string
+	Rows         []reportRow
+	BarChart     template.HTML
+	LatencyChart template.HTML
+	Plans        []reportPlan
+	Metrics      []reportMetric
+}
+
+// reportMetric is one server metric across the report's versions.
+type reportMetric struct {
+	// Label is the metric's name, marked per op for counters and as a peak
+	// for gauges.
+	Label string
+	// Values holds the median across runs for each of the report's
+	// versions, empty where a version has none.
+	Values []string
+}
+
+// reportPlan is one version's explain output for a query scenario.
+type reportPlan struct {
+	Version string
+	Plan    *queryPlan
+	// Changed is set when the winning plan differs from the baseline's.
+	Changed bool
+}
+
+// reportRow summarises one version's runs of a scenario.
+type reportRow struct {
+	Version string
+	Samples []float64
+	Mean    float64
+	Median  float64
+	Stddev  float64
+	CV      float64
+	Min     float64
+	Max     float64
+	// CILow and CIHigh bound the 95% confidence interval of the mean.
+	CILow  float64
+	CIHigh float64
+	// Latency holds the median across runs of each percentile.
+	Latency *latencySummary
+	// Delta is the change against the baseline, empty for the baseline.
+	Delta string
+}
+
+type reportEnvironment struct {
+	Version string
+	Details []string
+}
+
+var reportColors = []string{"#4e79a7", "#f28e2b", "#59a14f", "#e15759", "#76b7b2", "#edc948", "#b07aa1", "#9c755f"}
+
+func buildReport(set *sampleSet, title, baseline string, alpha float64, sources []string) (*report, error) {
+	versions, baseline, err := set.orderedVersions(baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &report{
+		Title:     title,
+		Generated: time.Now(),
+		Baseline:  baseline,
+		Alpha:     alpha,
+		Sources:   sources,
+		Versions:  versions,
+	}
+	for _, sc := range set.scenarios {
+		rs := reportScenario{Name: sc}
+		base := set.samples[benchKey{sc, baseline}]
+		for _, v := range versions {
+			xs := set.samples[benchKey{sc, v}]
+			// Runs that failed outright record 0 ns/op and have nothing to
+			// chart.
+			if len(xs) == 0 || slices.Max(xs) == 0 {
+				continue
+			}
+			row := reportRow{
+				Version: v,
+				Samples: xs,
+				Mean:    mean(xs),
+				Median:  median(xs),
+				Stddev:  stddev(xs),
+				Min:     slices.Min(xs),
+				Max:     slices.Max(xs),
+				Latency: medianLatency(set.latencies[benchKey{sc, v}]),
+			}
+			if row.Mean > 0 {
+				row.CV = row.Stddev / row.Mean * 100
+			}
+			row.CILow, row.CIHigh = confidenceInterval(xs)
+			row.CILow = math.Max(0, row.CILow)
+			if v != baseline && len(base) > 0 && mean(base) > 0 {
+				row.Delta = delta(base, xs).String(alpha)
+			}
+			rs.Rows = append(rs.Rows, row)
+		}
+		if len(rs.Rows) == 0 {
+			continue
+		}
+		basePlan := set.plans[benchKey{sc, baseline}]
+		for _, v := range versions {
+			if p := set.plans[benchKey{sc, v}]; p != nil {
+				changed := basePlan != nil && p.WinningPlan != basePlan.WinningPlan
+				rs.Plans = append(rs.Plans, reportPlan{Version: v, Plan: p, Changed: changed})
+			}
+		}
+		rs.Metrics = metricRows(set, sc, versions)
+		rs.BarChart = barChartSVG(rs.Rows, versions)
+		rs.LatencyChart = latencyChartSVG(rs.Rows, versions)
+		r.Scenarios = append(r.Scenarios, rs)
+	}
+
+	for _, v := range versions {
+		r.Environments = append(r.Environments, reportEnvironment{Version: v, Details: set.meta[v].describe()})
+	}
+	return r, nil
+}
+
+// metricRows lists the server metrics any version of the scenario recorded.
+func metricRows(set *sampleSet, scenario string, versions []string) []reportMetric {
+	var rows []reportMetric
+	for _, m := range serverMetricDefs {
+		row := reportMetric{Label: m.name + " / op", Values: make([]string, len(versions))}
+		if m.kind == gaugeMetric {
+			row.Label = m.name + " (peak)"
+		}
+		found := false
+		for i, v := range versions {
+			var xs []float64
+			for _, run := range set.serverMetrics[benchKey{scenario, v}] {
+				if x, ok := run[m.name]; ok {
+					xs = append(xs, x)
+				}
+			}
+			if len(xs) > 0 {
+				row.Values[i] = formatCount(median(xs))
+				found = true
+			}
+		}
+		if found {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// formatCount renders a metric with three significant digits and an SI
+// suffix, e.g. 1.23k or 45.6M.
+func formatCount(v float64) string {
+	for _, unit := range []struct {
+		scale  float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "G"}, {1e6, "M"}, {1e3, "k"}} {
+		if math.Abs(v) >= unit.scale {
+			return strconv.FormatFloat(v/unit.scale, 'g', 3, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+func (m *versionMeta) describe() []string {
+	if m == nil {
+		return nil
+	}
+	var details []string
+	if len(m.targets) > 0 {
+		details = append(details, "targets: "+strings.Join(m.targets, ", "))
+	}
+	for _, s := range m.servers {
+		details = append(details, fmt.Sprintf("server: MongoDB %s, %s, FCV %s, %s", s.Version, s.StorageEngine, s.FCV, s.describeTopology()))
+	}
+	for _, h := range m.hosts {
+		details = append(details, fmt.Sprintf("host: %s %s/%s, %s, %d CPUs, %s", h.Hostname, h.OS, h.Arch, h.CPU, h.NumCPU, h.GoVersion))
+	}
+	for _, key := range []string{"goos", "goarch", "cpu", "pkg"} {
+		if v, ok := m.header[key]; ok {
+			details = append(details, key+": "+v)
+		}
+	}
+	if len(m.runIDs) > 0 {
+		details = append(details, "runs: "+strings.Join(m.runIDs, ", "))
+	}
+	return details
+}
+
+func medianLatency(runs []latencySummary) *latencySummary {
+	if len(runs) == 0 {
+		return nil
+	}
+	pick := func(f func(latencySummary) int64) int64 {
+		xs := make([]float64, len(runs))
+		for i, l := range runs {
+			xs[i] = float64(f(l))
+		}
+		return int64(median(xs))
+	}
+	return &latencySummary{
+		Min:  pick(func(l latencySummary) int64 { return l.Min }),
+		P50:  pick(func(l latencySummary) int64 { return l.P50 }),
+		P90:  pick(func(l latencySummary) int64 { return l.P90 }),
+		P99:  pick(func(l latencySummary) int64 { return l.P99 }),
+		P999: pick(func(l latencySummary) int64 { return l.P999 }),
+		Max:  pick(func(l latencySummary) int64 { return l.Max }),
+	}
+}
+
+func versionColor(versions []string, v string) string {
+	return reportColors[slices.Index(versions, v)%len(reportColors)]
+}
+
+// barChartSVG draws one horizontal bar per version at the mean ns/op, with a
+// ±stddev whisker and a dot for every individual run.
+func barChartSVG(rows []reportRow, versions []string) template.HTML {
+	const (
+		width   = 640
+		labelW  = 70
+		valueW  = 80
+		rowH    = 30
+		padding = 10
+	)
+	var scale float64
+	for _, row := range rows {
+		scale = math.Max(scale, math.Max(row.Max, row.Mean+row.Stddev))
+	}
+	if scale == 0 {
+		return ""
+	}
+	plotW := float64(width - labelW - valueW)
+	x := func(v float64) float64 { return labelW + v/scale*plotW }
+
+	var b strings.Builder
+	height := len(rows)*rowH + 2*padding
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" class="chart">`, width, height)
+	for i, row := range rows {
+		y := float64(padding + i*rowH)
+		mid := y + rowH/2
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="label">%s</text>`, labelW-8, mid+4, html.EscapeString(row.Version))
+		fmt.Fprintf(&b, `<rect x="%d" y="%.1f" width="%.1f" height="%d" fill="%s" opacity="0.8"/>`,
+			labelW, y+4, x(row.Mean)-labelW, rowH-8, versionColor(versions, row.Version))
+		if row.Stddev > 0 {
+			lo, hi := x(math.Max(0, row.Mean-row.Stddev)), x(row.Mean+row.Stddev)
+			fmt.Fprintf(&b, `<line x1="%.1f" x2="%.1f" y1="%.1f" y2="%.1f" class="whisker"/>`, lo, hi, mid, mid)
+		}
+		for _, s := range row.Samples {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" class="run"/>`, x(s), mid)
+		}
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" class="value">%s</text>`, x(scale)+6, mid+4, formatNs(row.Mean))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// latencyChartSVG draws one line per version across p50..max on a log scale.
+func latencyChartSVG(rows []reportRow, versions []string) template.HTML {
+	const (
+		width   = 640
+		height  = 220
+		left    = 70
+		right   = 110
+		top     = 10
+		bottom  = 30
+		nPoints = 5
+	)
+	labels := []string{"p50", "p90", "p99", "p99.9", "max"}
+	points := func(l *latencySummary) []float64 {
+		return []float64{float64(l.P50), float64(l.P90), float64(l.P99), float64(l.P999), float64(l.Max)}
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, row := range rows {
+		if row.Latency == nil {
+			continue
+		}
+		for _, v := range points(row.Latency) {
+			v = math.Max(v, 1)
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	if math.IsInf(lo, 1) {
+		return ""
+	}
+	logLo, logHi := math.Floor(math.Log10(lo)), math.Ceil(math.Log10(hi))
+	if logHi == logLo {
+		logHi++
+	}
+	plotW, plotH := float64(width-left-right), float64(height-top-bottom)
+	x := func(i int) float64 { return left + float64(i)/float64(nPoints-1)*plotW }
+	y := func(v float64) float64 {
+		return top + plotH - (math.Log10(math.Max(v, 1))-logLo)/(logHi-logLo)*plotH
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" class="chart">`, width, height)
+	for e := logLo; e <= logHi; e++ {
+		v := math.Pow(10, e)
+		fmt.Fprintf(&b, `<line x1="%d" x2="%.1f" y1="%.1f" y2="%.1f" class="grid"/>`, left, x(nPoints-1), y(v), y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" class="label">%s</text>`, left-8, y(v)+4, formatNs(v))
+	}
+	for i, l := range labels {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" class="axis">%s</text>`, x(i), height-10, l)
+	}
+	legend := 0
+	for _, row := range rows {
+		if row.Latency == nil {
+			continue
+		}
+		color := versionColor(versions, row.Version)
+		var pts []string
+		for i, v := range points(row.Latency) {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(pts, " "), color)
+		ly := top + 14 + legend*18
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`, width-right+14, ly-10, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, width-right+32, ly, html.EscapeString(row.Version))
+		legend++
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+var reportFuncs = template.FuncMap{
+	"ns":  formatNs,
+	"pct": func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"lat": func(v int64) string { return formatNs(float64(v)) },
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 1000px; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1em; font-size: 0.9em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.chart text { font-size: 12px; }
+.chart .label { text-anchor: end; }
+.chart .axis { text-anchor: middle; }
+.chart .grid { stroke: #eee; }
+.chart .whisker { stroke: #222; stroke-width: 1.5; }
+.chart .run { fill: #222; opacity: 0.6; }
+.meta { color: #666; font-size: 0.85em; }
+td.plan { text-align: left; font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} from {{range $i, $s := .Sources}}{{if $i}}, {{end}}{{$s}}{{end}}.
+Changes are against {{.Baseline}}; "~" marks differences that are not significant at p &le; {{.Alpha}} (Mann-Whitney U).</p>
+
+<h2>Environment</h2>
+{{range .Environments}}<h3>{{.Version}}</h3>
+<ul>{{range .Details}}<li>{{.}}</li>{{end}}</ul>
+{{end}}
+{{range .Scenarios}}
+<h2>{{.Name}}</h2>
+<p class="meta">Mean time per operation, whiskers at &plusmn;1 stddev, dots are individual runs.</p>
+{{.BarChart}}
+<table>
+<tr><th>version</th><th>runs</th><th>mean</th><th>median</th><th>stddev</th><th>CV</th><th>95% CI</th><th>min</th><th>max</th><th>vs {{$.Baseline}}</th></tr>
+{{range .Rows}}<tr><td>{{.Version}}</td><td>{{len .Samples}}</td><td>{{ns .Mean}}</td><td>{{ns .Median}}</td><td>{{ns .Stddev}}</td><td>{{pct .CV}}</td><td>{{ns .CILow}} .. {{ns .CIHigh}}</td><td>{{ns .Min}}</td><td>{{ns .Max}}</td><td>{{.Delta}}</td></tr>
+{{end}}</table>
+{{if .LatencyChart}}<p class="meta">Per-operation latency percentiles (median across runs, log scale).</p>
+{{.LatencyChart}}
+<table>
+<tr><th>version</th><th>p50</th><th>p90</th><th>p99</th><th>p99.9</th><th>max</th></tr>
+{{range .Rows}}{{if .Latency}}<tr><td>{{.Version}}</td><td>{{lat .Latency.P50}}</td><td>{{lat .Latency.P90}}</td><td>{{lat .Latency.P99}}</td><td>{{lat .Latency.P999}}</td><td>{{lat .Latency.Max}}</td></tr>
+{{end}}{{end}}</table>{{end}}
+{{if .Plans}}<p class="meta">Query plan from explain("executionStats"); <b>bold</b> plans differ from {{$.Baseline}}.</p>
+<table>
+<tr><th>version</th><th>winning plan</th><th>returned</th><th>keys examined</th><th>docs examined</th><th>time</th></tr>
+{{range .Plans}}<tr><td>{{.Version}}</td><td class="plan">{{if .Changed}}<b>{{.Plan.WinningPlan}}</b>{{else}}{{.Plan.WinningPlan}}{{end}}</td><td>{{.Plan.NReturned}}</td><td>{{.Plan.KeysExamined}}</td><td>{{.Plan.DocsExamined}}</td><td>{{.Plan.ExecutionTimeMs}}ms</td></tr>
+{{end}}</table>{{end}}
+{{if .Metrics}}<p class="meta">Server metrics during the measured iterations (median across runs).</p>
+<table>
+<tr><th>server metric</th>{{range $.Versions}}<th>{{.}}</th>{{end}}</tr>
+{{range .Metrics}}<tr><td>{{.Label}}</td>{{range .Values}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// writeMarkdown renders the report as Markdown, with text bars standing in
+// for the charts.
+func (r *report) writeMarkdown(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Title)
+	fmt.Fprintf(&b, "Generated %s from %s. Changes are against %s; \"~\" marks differences that are not significant at p <= %v (Mann-Whitney U).\n\n",
+		r.Generated.Format("2006-01-02 15:04:05 MST"), strings.Join(r.Sources, ", "), r.Baseline, r.Alpha)
+
+	b.WriteString("## Environment\n\n")
+	for _, env := range r.Environments {
+		fmt.Fprintf(&b, "**%s**\n\n", env.Version)
+		for _, d := range env.Details {
+			fmt.Fprintf(&b, "- %s\n", d)
+		}
+		b.WriteString("\n")
+	}
+
+	const barWidth = 30
+	for _, sc := range r.Scenarios {
+		fmt.Fprintf(&b, "## %s\n\n", sc.Name)
+		var scale float64
+		for _, row := range sc.Rows {
+			scale = math.Max(scale, row.Mean)
+		}
+		fmt.Fprintf(&b, "| version | mean | | runs | median | stddev | CV | 95%% CI | min | max | vs %s |\n", r.Baseline)
+		b.WriteString("|---|---:|---|---:|---:|---:|---:|---:|---:|---:|---|\n")
+		for _, row := range sc.Rows {
+			bar := ""
+			if scale > 0 {
+				bar = strings.Repeat("█", int(math.Round(row.Mean/scale*barWidth)))
+			}
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %d | %s | %s | %.1f%% | %s .. %s | %s | %s | %s |\n",
+				row.Version, formatNs(row.Mean), bar, len(row.Samples), formatNs(row.Median), formatNs(row.Stddev),
+				row.CV, formatNs(row.CILow), formatNs(row.CIHigh), formatNs(row.Min), formatNs(row.Max), row.Delta)
+		}
+		b.WriteString("\n")
+
+		if sc.LatencyChart != "" {
+			b.WriteString("| version | p50 | p90 | p99 | p99.9 | max |\n|---|---:|---:|---:|---:|---:|\n")
+			for _, row := range sc.Rows {
+				if l := row.Latency; l != nil {
+					fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", row.Version,
+						formatNs(float64(l.P50)), formatNs(float64(l.P90)), formatNs(float64(l.P99)),
+						formatNs(float64(l.P999)), formatNs(float64(l.Max)))
+				}
+			}
+			b.WriteString("\n")
+		}
+
+		if len(sc.Plans) > 0 {
+			b.WriteString("| version | winning plan | returned | keys examined | docs examined | time |\n|---|---|---:|---:|---:|---:|\n")
+			for _, p := range sc.Plans {
+				plan := "`" + p.Plan.WinningPlan + "`"
+				if p.Changed {
+					plan = "**" + plan + "** (changed)"
+				}
+				fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %dms |\n", p.Version, plan,
+					p.Plan.NReturned, p.Plan.KeysExamined, p.Plan.DocsExamined, p.Plan.ExecutionTimeMs)
+			}
+			b.WriteString("\n")
+		}
+
+		if len(sc.Metrics) > 0 {
+			fmt.Fprintf(&b, "| server metric | %s |\n|---|%s\n", strings.Join(r.Versions, " | "), strings.Repeat("---:|", len(r.Versions)))
+			for _, m := range sc.Metrics {
+				fmt.Fprintf(&b, "| %s | %s |\n", m.Label, strings.Join(m.Values, " | "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeReportFile(path string, render func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := render(f); err != nil {
+		f.Close()
+		return err
+	}
+	log.Println("Wrote", path)
+	return f.Close()
+}
+
+func reportCommand(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	htmlPath := fs.String("html", "report.html", "HTML report to write; empty to skip")
+	mdPath := fs.String("md", "report.md", "Markdown report to write; empty to skip")
+	title := fs.String("title", "MongoDB version comparison", "report title")
+	baseline := fs.String("baseline", "", "version to compare against, a target name or for go test logs a release series (default the oldest)")
+	alpha := fs.Float64("alpha", 0.05, "significance level for the Mann-Whitney U test")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: mongoVersionSpeedTest report [flags] file...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("report needs at least one result file")
+	}
+
+	set := newSampleSet()
+	for _, path := range fs.Args() {
+		if err := set.loadFile(path); err != nil {
+			return err
+		}
+	}
+	if len(set.samples) == 0 {
+		return errors.New("no benchmark results found")
+	}
+	r, err := buildReport(set, *title, *baseline, *alpha, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if *htmlPath != "" {
+		err := writeReportFile(*htmlPath, func(w io.Writer) error { return htmlReport.Execute(w, r) })
+		if err != nil {
+			return err
+		}
+	}
+	if *mdPath != "" {
+		if err := writeReportFile(*mdPath, r.writeMarkdown); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReportRendersChartsAndEnvironment(t *testing.T) {
+	set := newSampleSet()
+	for i, version := range []string{"5.0.14", "8.0.4"} {
+		for run := 0; run < 3; run++ {
+			set.addResult(result{
+				RunID:      "run1",
+				Target:     "mongo" + strings.ReplaceAll(version[:3], ".", ""),
+				Server:     &serverInfo{Version: version, StorageEngine: "wiredTiger", FCV: version[:3], Topology: "standalone"},
+				Host:       &hostInfo{Hostname: "bench", OS: "linux", Arch: "amd64", NumCPU: 12},
+				Scenario:   "UpdateOne",
+				NsPerOp:    float64(100000 + i*10000 + run*1000),
+				Iterations: 100,
+				ServerMetrics: &serverMetrics{
+					Deltas: map[string]float64{"opcounters.update": float64(100 * (i + 1)), "cache.bytesRead": 250000},
+					Peaks:  map[string]float64{"tickets.writeOut": 3},
+				},
+				Latency: &latencySummary{P50: 90000, P90: 120000, P99: 300000, P999: 900000, Max: 2000000},
+				Plan:    &queryPlan{WinningPlan: []string{"COLLSCAN", "FETCH > IXSCAN(updated_1)"}[i], KeysExamined: int64(i)},
+			})
+		}
+	}
+
+	r, err := buildReport(set, "test", "", 0.05, []string{"a.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Baseline != "mongo50" || len(r.Scenarios) != 1 || len(r.Scenarios[0].Rows) != 2 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	var htmlOut bytes.Buffer
+	if err := htmlReport.Execute(&htmlOut, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<polyline", "<circle", "MongoDB 8.0.4, wiredTiger", "9.9% p=0.100", "<b>FETCH &gt; IXSCAN(updated_1)</b>"} {
+		if !strings.Contains(htmlOut.String(), want) {
+			t.Errorf("HTML report lacks %q", want)
+		}
+	}
+
+	var md bytes.Buffer
+	if err := r.writeMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## UpdateOne", "| mongo80 | 111µs", "| mongo50 | 90µs | 120µs | 300µs | 900µs | 2ms |", "| 98.5µs .. 103µs |",
+		"| mongo80 | **`FETCH > IXSCAN(updated_1)`** (changed) | 0 | 1 | 0 | 0ms |",
+		"| server metric | mongo50 | mongo80 |", "| opcounters.update / op | 1 | 2 |", "| cache.bytesRead / op | 2.5k | 2.5k |", "| tickets.writeOut (peak) | 3 | 3 |"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Markdown report lacks %q:\n%s", want, md.String())
+		}
+	}
+}
+
+func TestReportLeavesOutFailedRuns(t *testing.T) {
+	set := newSampleSet()
+	for _, version := range []string{"5.0.14", "8.0.4"} {
+		set.addResult(result{Server: &serverInfo{Version: version}, Scenario: "FindOne", Errors: 1, FirstError: "connection refused"})
+	}
+	if len(set.samples) != 0 {
+		t.Fatalf("failed runs were kept as samples: %v", set.samples)
+	}
+	// Older result files can still hold runs that recorded 0 ns/op, which
+	// the report has to leave out itself.
+	set.add("FindOne", "5.0", 0)
+	set.add("FindOne", "8.0", 0)
+	set.add("UpdateOne", "5.0", 0)
+	set.add("UpdateOne", "8.0", 5000)
+
+	r, err := buildReport(set, "test", "", 0.05, []string{"a.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var md bytes.Buffer
+	if err := r.writeMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(md.String(), "NaN") || strings.Contains(md.String(), "Inf") || strings.Contains(md.String(), "FindOne") ||
+		!strings.Contains(md.String(), "| 8.0 | 5µs |") {
+		t.Errorf("unexpected Markdown report:\n%s", md.String())
+	}
+	var htmlOut bytes.Buffer
+	if err := htmlReport.Execute(&htmlOut, r); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(htmlOut.String(), "NaN") || strings.Contains(htmlOut.String(), "Inf") {
+		t.Errorf("HTML report has NaN or Inf:\n%s", htmlOut.String())
+	}
+}
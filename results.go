@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// result is the structured record of one scenario run on one target.
+type result struct {
+	RunID     string            `json:"runId"`
+	Timestamp time.Time         `json:"timestamp"`
+	Target    string            `json:"target"`
+	Server    *serverInfo       `json:"server,omitempty"`
+	Host      *hostInfo         `json:"host,omitempty"`
+	Scenario  string            `json:"scenario"`
+	Params    map[string]string `json:"params,omitempty"`
+	Workers   int               `json:"workers"`
+	// Repeat numbers the repetition under -repeat.
+	Repeat     int             `json:"repeat,omitempty"`
+	Iterations int             `json:"iterations"`
+	DurationNs int64           `json:"durationNs"`
+	NsPerOp    float64         `json:"nsPerOp"`
+	OpsPerSec  float64         `json:"opsPerSec"`
+	DocsPerSec float64         `json:"docsPerSec,omitempty"`
+	MBPerSec   float64         `json:"mbPerSec,omitempty"`
+	Latency    *latencySummary `json:"latencyNs,omitempty"`
+	Plan       *queryPlan      `json:"plan,omitempty"`
+	Warmup     *warmupSummary  `json:"warmup,omitempty"`
+	// ThroughputCV is the coefficient of variation of the measured ops/s
+	// over -steady-window windows, when the run lasted steadyWindows of
+	// them. Unsteady marks runs whose warmup or measurement never settled
+	// within -steady-cv.
+	ThroughputCV float64 `json:"throughputCv,omitempty"`
+	Unsteady     bool    `json:"unsteady,omitempty"`
+	// ServerMetrics is what the server did during the measured iterations.
+	ServerMetrics *serverMetrics `json:"serverMetrics,omitempty"`
+	Errors        int            `json:"errors"`
+	FirstError    string         `json:"firstError,omitempty"`
+}
+
+// hostInfo describes the machine the run command ran on.
+type hostInfo struct {
+	Hostname  string `json:"hostname,omitempty"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPU       string `json:"cpu,omitempty"`
+	NumCPU    int    `json:"numCpu"`
+	GoVersion string `json:"goVersion"`
+}
+
+func collectHostInfo() *hostInfo {
+	host := &hostInfo{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		NumCPU:    runtime.NumCPU(),
+		GoVersion: runtime.Version(),
+	}
+	host.Hostname, _ = os.Hostname()
+	// Same source go test uses for its "cpu:" header line on Linux.
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if name, ok := strings.CutPrefix(line, "model name"); ok {
+				_, host.CPU, _ = strings.Cut(name, ":")
+				host.CPU = strings.TrimSpace(host.CPU)
+				break
+			}
+		}
+	}
+	return host
+}
+
+func (r *result) setParam(key, value string) {
+	if r.Params == nil {
+		r.Params = map[string]string{}
+	}
+	r.Params[key] = value
+}
+
+// paramString renders params as sorted key=value pairs joined by ';'.
+func (r *result) paramString() string {
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + r.Params[k]
+	}
+	return strings.Join(pairs, ";")
+}
+
+func newRunID() string {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// resultWriter fans results out to JSON Lines on stdout and, when dir is set,
+// to <dir>/<runID>.jsonl and <dir>/<runID>.csv.
+type resultWriter struct {
+	stdout *json.Encoder
+	jsonl  *os.File
+	enc    *json.Encoder
+	csvF   *os.File
+	csv    *csv.Writer
+}
+
+// csvHeader ends with one column per server metric, named after it with
+// dots as underscores, e.g. srv_opcounters_insert.
+var csvHeader = append([]string{
+	"run_id", "timestamp", "target", "server_version", "storage_engine", "fcv", "topology", "primary", "members", "shards",
+	"scenario", "params", "workers", "repeat", "iterations", "duration_ns", "ns_per_op", "ops_per_sec", "docs_per_sec", "mb_per_sec",
+	"lat_min_ns", "lat_mean_ns", "lat_p50_ns", "lat_p90_ns", "lat_p99_ns", "lat_p999_ns", "lat_max_ns",
+	"errors", "first_error", "winning_plan", "keys_examined", "docs_examined",
+	"warmup_ops", "throughput_cv", "unsteady",
+}, serverMetricColumns()...)
+
+func serverMetricColumns() []string {
+	columns := make([]string, len(serverMetricDefs))
+	for i, m := range serverMetricDefs {
+		columns[i] = "srv_" + strings.ReplaceAll(m.name, ".", "_")
+	}
+	return columns
+}
+
+func openResultWriters(dir, runID string) (*resultWriter, error) {
+	w := &resultWriter{stdout: json.NewEncoder(os.Stdout)}
+	if dir == "" {
+		return w, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var err error
+	base := filepath.Join(dir, runID)
+	if w.jsonl, err = os.Create(base + ".jsonl"); err != nil {
+		return nil, err
+	}
+	w.enc = json.NewEncoder(w.jsonl)
+	if w.csvF, err = os.Create(base + ".csv"); err != nil {
+		w.jsonl.Close()
+		return nil, err
+	}
+	w.csv = csv.NewWriter(w.csvF)
+	if err := w.csv.Write(csvHeader); err != nil {
+		w.close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *resultWriter) write(res result) error {
+	if err := w.stdout.Encode(res); err != nil {
+		return err
+	}
+	if w.jsonl == nil {
+		return nil
+	}
+	if err := w.enc.Encode(res); err != nil {
+		return err
+	}
+	if err := w.csv.Write(csvRow(res)); err != nil {
+		return err
+	}
+	// Flush per row so an interrupted run still leaves usable files.
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *resultWriter) close() error {
+	if w.jsonl == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return errors.Join(w.csv.Error(), w.jsonl.Close(), w.csvF.Close())
+}
+
+func csvRow(res result) []string {
+	var server serverInfo
+	if res.Server != nil {
+		server = *res.Server
+	}
+	var lat latencySummary
+	if res.Latency != nil {
+		lat = *res.Latency
+	}
+
+	var plan queryPlan
+	if res.Plan != nil {
+		plan = *res.Plan
+	}
+	var warmup warmupSummary
+	if res.Warmup != nil {
+		warmup = *res.Warmup
+	}
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	i := func(v int64) string { return strconv.FormatInt(v, 10) }
+	row := []string{
+		res.RunID, res.Timestamp.Format(time.RFC3339Nano), res.Target,
+		server.Version, server.StorageEngine, server.FCV, server.Topology,
+		server.Primary, strings.Join(server.Members, " "), strconv.Itoa(server.Shards),
+		res.Scenario, res.paramString(), strconv.Itoa(res.Workers), strconv.Itoa(res.Repeat), strconv.Itoa(res.Iterations),
+		i(res.DurationNs), f(res.NsPerOp), f(res.OpsPerSec), f(res.DocsPerSec), f(res.MBPerSec),
+		i(lat.Min), f(lat.Mean), i(lat.P50), i(lat.P90), i(lat.P99), i(lat.P999), i(lat.Max),
+		strconv.Itoa(res.Errors), res.FirstError,
+		plan.WinningPlan, i(plan.KeysExamined), i(plan.DocsExamined),
+		strconv.Itoa(warmup.Ops), f(res.ThroughputCV), strconv.FormatBool(res.Unsteady),
+	}
+	for _, m := range serverMetricDefs {
+		v, ok := res.ServerMetrics.value(m)
+		if !ok {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, f(v))
+	}
+	return row
+}
+
+// readResults reads a JSON Lines file written by the run command.
+func readResults(r io.Reader) ([]result, error) {
+	var results []result
+	dec := json.NewDecoder(r)
+	for {
+		var res result
+		err := dec.Decode(&res)
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+}
+
+// variant names a result's scenario together with the settings that make its
+// numbers incomparable to other runs of the same scenario.
+func (r *result) variant() string {
+	var extra []string
+	if r.Workers > 1 {
+		extra = append(extra, "workers="+strconv.Itoa(r.Workers))
+	}
+	return r.labelWith(extra, "docsPerOp")
+}
+
+// scenarioLabel is variant without the concurrency settings, naming the
+// curve a worker sweep traces.
+func (r *result) scenarioLabel() string {
+	return r.labelWith(nil, "docsPerOp", "clients")
+}
+
+func (r *result) labelWith(extra []string, skip ...string) string {
+	keys := make([]string, 0, len(r.Params))
+	for k := range r.Params {
+		if !slices.Contains(skip, k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		extra = append(extra, k+"="+r.Params[k])
+	}
+	if len(extra) == 0 {
+		return r.Scenario
+	}
+	return r.Scenario + "[" + strings.Join(extra, ",") + "]"
+}
+
+// versionLabel is what results are grouped by when comparing versions: the
+// target name, since several targets can run the same release in different
+// topologies or on different hosts, or the release series the server
+// reported for results without one.
+func (r *result) versionLabel() string {
+	if r.Target == "" && r.Server != nil {
+		return releaseSeries(r.Server.Version)
+	}
+	return r.Target
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResultWriterRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	w, err := openResultWriters(dir, "run1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := result{
+		RunID:      "run1",
+		Timestamp:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Target:     "mongo80",
+		Server:     &serverInfo{Version: "8.0.4", Topology: "standalone"},
+		Scenario:   "UpdateOne",
+		Workers:    4,
+		Iterations: 1000,
+		Latency:    &latencySummary{P50: 120000, P99: 900000},
+	}
+	res.setParam("clients", "shared")
+	res.setParam("docsPerOp", "1")
+	// Write to the files only, keeping the test's stdout clean.
+	if err := w.enc.Encode(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.csv.Write(csvRow(res)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "run1.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := readResults(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Server.Version != "8.0.4" || got[0].Latency.P99 != 900000 || got[0].Params["clients"] != "shared" {
+		t.Errorf("unexpected results %+v", got)
+	}
+
+	cf, err := os.Open(filepath.Join(dir, "run1.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cf.Close()
+	rows, err := csv.NewReader(cf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[1]) != len(csvHeader) {
+		t.Fatalf("unexpected csv %q", rows)
+	}
+	if rows[1][slices.Index(csvHeader, "params")] != "clients=shared;docsPerOp=1" {
+		t.Errorf("params column = %q", rows[1][slices.Index(csvHeader, "params")])
+	}
+}
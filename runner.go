@@ -0,0 +1,1072 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type runConfig struct {
+	targetsFile    string
+	targets        []string
+	scenarios      []string
+	iterations     int
+	docs           int
+	database       string
+	collection     string
+	gridFSDatabase string
+	filePath       string
+	versionCheck   string
+	histogramDir   string
+	workers        []int
+	batchSizes     []int
+	orderedModes   []bool
+	batchDocs      int
+	clientMode     string
+	writeConcerns  []string
+	readConcerns   []string
+	readPrefs      []string
+	outDir         string
+	runID          string
+	host           *hostInfo
+	gen            *docGenerator
+	indexGen       *docGenerator
+	indexDocs      int
+	indexKinds     []string
+	mixes          []*opMix
+	duration       time.Duration
+	opLimit        int
+	rate           float64
+	warmup         time.Duration
+	steadyWindow   time.Duration
+	steadyCV       float64
+	metricsEvery   time.Duration
+	repeat         int
+	shuffle        bool
+	interleave     bool
+	slices         int
+	aggDocs        int
+	allowDiskUse   []bool
+	aggDecode      []bool
+	docgenPath     string
+}
+
+const (
+	clientsShared    = "shared"
+	clientsPerWorker = "per-worker"
+)
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// defaultRunConfig holds the settings the go test suite uses and the run
+// command's flag defaults.
+func defaultRunConfig() *runConfig {
+	return &runConfig{
+		scenarios:      defaultScenarios,
+		iterations:     1000,
+		docs:           1000000,
+		database:       "benchmarkMain",
+		collection:     "files",
+		gridFSDatabase: "benchmarkGridFS",
+		filePath:       "./fileForInsert.txt",
+		versionCheck:   versionCheckStrict,
+		workers:        []int{1},
+		batchSizes:     []int{1, 10, 100, 1000, 10000, 100000},
+		orderedModes:   []bool{true, false},
+		batchDocs:      100000,
+		clientMode:     clientsShared,
+		writeConcerns:  []string{""},
+		readConcerns:   []string{""},
+		readPrefs:      []string{""},
+		runID:          newRunID(),
+		host:           collectHostInfo(),
+		gen:            newDocGenerator(defaultDocTemplate()),
+		indexGen:       newDocGenerator(indexDocTemplate(defaultDocTemplate().Seed)),
+		indexDocs:      100000,
+		indexKinds:     indexKindNames(),
+		aggDocs:        10000,
+		allowDiskUse:   []bool{true},
+		aggDecode:      []bool{true, false},
+		steadyWindow:   time.Second,
+		steadyCV:       0.1,
+		repeat:         1,
+		slices:         1,
+	}
+}
+
+func parseRunFlags(args []string) (*runConfig, error) {
+	cfg := defaultRunConfig()
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	targets := fs.String("targets", "", "comma-separated target names or tag:<tag> selectors (default all)")
+	scenarioList := fs.String("scenarios", strings.Join(cfg.scenarios, ","), "comma-separated scenario names or globs like Agg*, run in the given order; Name@1/4/16 sets the worker counts for the scenarios an entry selects")
+	fs.StringVar(&cfg.docgenPath, "docgen", "", "YAML/JSON document template for insert workloads (default the four-field myFile documents)")
+	seed := fs.Uint64("seed", 0, "document generator seed (default the template's)")
+	workers := fs.String("workers", "1", "comma-separated worker counts to sweep concurrent scenarios over")
+	fs.StringVar(&cfg.targetsFile, "targets-file", "", "YAML/JSON target registry (default $"+targetsFileEnv+")")
+	fs.IntVar(&cfg.iterations, "n", cfg.iterations, "iterations per scenario")
+	fs.DurationVar(&cfg.duration, "duration", 0, "run each scenario for this long instead of -n iterations, or until -n if that is given too; batch scenarios still run their fixed count")
+	fs.IntVar(&cfg.repeat, "repeat", cfg.repeat, "run every scenario this many times on each target and summarise the spread")
+	fs.BoolVar(&cfg.shuffle, "shuffle", false, "with -repeat, visit the targets in a different order, drawn from -seed, in each repetition")
+	fs.BoolVar(&cfg.interleave, "interleave", false, "run each scenario round-robin across the targets, one run per target in turn, so all of them see the same machine conditions")
+	fs.IntVar(&cfg.slices, "slices", cfg.slices, "with -interleave, split each run's -n or -duration into this many slices and alternate the targets slice by slice; a run is still seeded and warmed up once and reported as one result")
+	fs.DurationVar(&cfg.warmup, "warmup", 0, "untimed warmup before each scenario, ended early once throughput is steady; batch scenarios aren't warmed up")
+	fs.DurationVar(&cfg.steadyWindow, "steady-window", cfg.steadyWindow, "window throughput is sampled over to judge whether it is steady")
+	fs.Float64Var(&cfg.steadyCV, "steady-cv", cfg.steadyCV, "coefficient of variation of windowed throughput below which a run counts as steady")
+	fs.DurationVar(&cfg.metricsEvery, "metrics-interval", time.Second, "how often to sample serverStatus while a scenario runs, for the server metrics stored with its result; 0 to not collect them")
+	fs.Float64Var(&cfg.rate, "rate", 0, "open-loop rate limit in ops/s per scenario across all workers; latency is measured from each op's scheduled start (0 for closed loop)")
+	fs.IntVar(&cfg.docs, "docs", cfg.docs, "documents inserted by one InsertMany iteration")
+	fs.StringVar(&cfg.database, "db", cfg.database, "database for collection scenarios")
+	fs.StringVar(&cfg.collection, "collection", cfg.collection, "collection for collection scenarios")
+	fs.StringVar(&cfg.gridFSDatabase, "gridfs-db", cfg.gridFSDatabase, "database for GridFS scenarios")
+	fs.StringVar(&cfg.filePath, "file", cfg.filePath, "file uploaded by GridFS scenarios")
+	fs.StringVar(&cfg.versionCheck, "version-check", cfg.versionCheck, "what to do when a server doesn't match its target's version: strict, warn or off")
+	fs.StringVar(&cfg.histogramDir, "histograms", "", "directory to write per-scenario latency histograms (.hgrm) to")
+	batchSizes := fs.String("batch-sizes", "1,10,100,1000,10000,100000", "InsertManyBatched batch sizes to sweep")
+	ordered := fs.String("ordered", "true,false", "InsertManyBatched ordered modes to sweep")
+	fs.IntVar(&cfg.batchDocs, "batch-docs", cfg.batchDocs, "documents InsertManyBatched inserts per batch size")
+	fs.IntVar(&cfg.indexDocs, "index-docs", cfg.indexDocs, "documents in the index suite's collection")
+	indexKindList := fs.String("indexes", strings.Join(cfg.indexKinds, ","), "index kinds the Index* scenarios sweep")
+	fs.IntVar(&cfg.aggDocs, "agg-docs", cfg.aggDocs, "documents in the aggregation suite's collection")
+	allowDiskUse := fs.String("allow-disk-use", "true", "Agg* allowDiskUse settings to sweep")
+	aggDecode := fs.String("agg-decode", "true,false", "Agg* result deserialization settings to sweep")
+	mixFiles := fs.String("workload", "", "comma-separated YAML workload files, each run as a Mix scenario after -scenarios (which then defaults to none); see mix.example.yaml")
+	fs.StringVar(&cfg.clientMode, "clients", cfg.clientMode, "how workers connect: shared (one mongo.Client) or per-worker")
+	writeConcerns := fs.String("write-concerns", concernDefault, "comma-separated write concerns to run every scenario with: default, a w value (0, 1, majority, ...), j, or both like majority+j")
+	readConcerns := fs.String("read-concerns", concernDefault, "comma-separated read concerns to run every scenario with: default, local, available, majority, linearizable or snapshot")
+	readPrefs := fs.String("read-prefs", concernDefault, "comma-separated read preferences to run every scenario with: default, primary, primaryPreferred, secondary, secondaryPreferred or nearest")
+	fs.StringVar(&cfg.outDir, "out-dir", "results", "directory for the run's <run id>.jsonl and .csv result files; empty to only print JSON Lines to stdout")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.targets = splitList(*targets)
+	cfg.scenarios = splitList(*scenarioList)
+	var err error
+	if cfg.workers, err = parseCounts(splitList(*workers)); err != nil {
+		return nil, fmt.Errorf("-workers: %w", err)
+	}
+	if cfg.batchSizes, err = parseCounts(splitList(*batchSizes)); err != nil {
+		return nil, fmt.Errorf("-batch-sizes: %w", err)
+	}
+	if cfg.orderedModes, err = parseBools(splitList(*ordered)); err != nil {
+		return nil, fmt.Errorf("-ordered: %w", err)
+	}
+	if cfg.allowDiskUse, err = parseBools(splitList(*allowDiskUse)); err != nil {
+		return nil, fmt.Errorf("-allow-disk-use: %w", err)
+	}
+	if cfg.aggDecode, err = parseBools(splitList(*aggDecode)); err != nil {
+		return nil, fmt.Errorf("-agg-decode: %w", err)
+	}
+	if cfg.writeConcerns, err = parseConcernList(*writeConcerns, parseWriteConcern); err != nil {
+		return nil, fmt.Errorf("-write-concerns: %w", err)
+	}
+	if cfg.readConcerns, err = parseConcernList(*readConcerns, parseReadConcern); err != nil {
+		return nil, fmt.Errorf("-read-concerns: %w", err)
+	}
+	if cfg.readPrefs, err = parseConcernList(*readPrefs, parseReadPref); err != nil {
+		return nil, fmt.Errorf("-read-prefs: %w", err)
+	}
+	if cfg.batchDocs < 1 {
+		return nil, errors.New("-batch-docs must be positive")
+	}
+	tmpl := defaultDocTemplate()
+	if cfg.docgenPath != "" {
+		if tmpl, err = loadDocTemplate(cfg.docgenPath); err != nil {
+			return nil, err
+		}
+	}
+	if *seed != 0 {
+		tmpl.Seed = *seed
+	}
+	cfg.gen = newDocGenerator(tmpl)
+	cfg.indexGen = newDocGenerator(indexDocTemplate(tmpl.Seed))
+	cfg.indexKinds = splitList(*indexKindList)
+	for _, name := range cfg.indexKinds {
+		if _, ok := lookupIndexKind(name); !ok {
+			return nil, fmt.Errorf("-indexes: unknown index kind %q", name)
+		}
+	}
+	if cfg.indexDocs < 1 {
+		return nil, errors.New("-index-docs must be positive")
+	}
+	for _, path := range splitList(*mixFiles) {
+		m, err := loadOpMix(path)
+		if err != nil {
+			return nil, err
+		}
+		if m.Docs == 0 {
+			m.Docs = cfg.docs
+		}
+		if m.Seed == 0 {
+			m.Seed = tmpl.Seed
+		}
+		cfg.mixes = append(cfg.mixes, m)
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if len(cfg.mixes) > 0 && !set["scenarios"] {
+		cfg.scenarios = nil
+	}
+	if cfg.duration < 0 || cfg.rate < 0 || cfg.warmup < 0 || cfg.metricsEvery < 0 {
+		return nil, errors.New("-duration, -rate, -warmup and -metrics-interval can't be negative")
+	}
+	if cfg.repeat < 1 {
+		return nil, errors.New("-repeat must be positive")
+	}
+	if cfg.slices < 1 {
+		return nil, errors.New("-slices must be positive")
+	}
+	if cfg.slices > 1 && !cfg.interleave {
+		return nil, errors.New("-slices needs -interleave")
+	}
+	if cfg.steadyWindow <= 0 || cfg.steadyCV <= 0 {
+		return nil, errors.New("-steady-window and -steady-cv must be positive")
+	}
+	if cfg.duration > 0 && set["n"] {
+		cfg.opLimit = cfg.iterations
+	}
+	if cfg.aggDocs < 1 {
+		return nil, errors.New("-agg-docs must be positive")
+	}
+	if cfg.clientMode != clientsShared && cfg.clientMode != clientsPerWorker {
+		return nil, fmt.Errorf("unknown -clients mode %q", cfg.clientMode)
+	}
+	if cfg.iterations < 1 {
+		return nil, errors.New("-n must be positive")
+	}
+	if cfg.docs < 1 {
+		return nil, errors.New("-docs must be positive")
+	}
+	switch cfg.versionCheck {
+	case versionCheckStrict, versionCheckWarn, versionCheckOff:
+	default:
+		return nil, fmt.Errorf("unknown -version-check mode %q", cfg.versionCheck)
+	}
+	return cfg, nil
+}
+
+func runCommand(args []string) error {
+	cfg, err := parseRunFlags(args)
+	if err != nil {
+		return err
+	}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		return err
+	}
+	registry, err := loadTargets(cfg.targetsFile)
+	if err != nil {
+		return err
+	}
+	targets, err := selectTargets(registry, cfg.targets)
+	if err != nil {
+		return err
+	}
+
+	out, err := openResultWriters(cfg.outDir, cfg.runID)
+	if err != nil {
+		return err
+	}
+	log.Println("Run", cfg.runID)
+
+	// Interrupting the run still stops any mongod it launched.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Every target stays connected, and launched, for the whole run so
+	// repetitions can move between them.
+	var sessions []*targetSession
+	failed := false
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		s, err := openTarget(ctx, cfg, t)
+		if err != nil {
+			log.Printf("Target %s: %v", t.Name, err)
+			failed = true
+			continue
+		}
+		defer s.close(ctx)
+		sessions = append(sessions, s)
+	}
+
+	var results []result
+	emit := func(res result) error {
+		results = append(results, res)
+		return out.write(res)
+	}
+	var shuffler *rand.Rand
+	if cfg.shuffle {
+		shuffler = rand.New(rand.NewPCG(cfg.gen.tmpl.Seed, 0))
+	}
+	if cfg.interleave {
+		failed = runInterleaved(ctx, cfg, sessions, planned, shuffler, emit) || failed
+	} else {
+		failed = runSequential(ctx, cfg, sessions, planned, shuffler, emit) || failed
+	}
+	if err := out.close(); err != nil {
+		return err
+	}
+	printScaling(os.Stderr, results)
+	printBatchSweep(os.Stderr, results)
+	if cfg.repeat > 1 && len(results) > 0 {
+		set := newSampleSet()
+		for _, res := range results {
+			set.addResult(res)
+		}
+		if len(set.samples) > 0 {
+			if err := set.writeSummary(os.Stderr); err != nil {
+				return err
+			}
+		}
+	}
+	if failed {
+		return errors.New("some targets failed")
+	}
+	return nil
+}
+
+// runSequential runs every planned scenario on one target after the other,
+// once per repetition. It reports whether any target failed.
+func runSequential(ctx context.Context, cfg *runConfig, sessions []*targetSession, planned []plannedScenario, shuffler *rand.Rand, emit func(result) error) bool {
+	failed := false
+	for rep := 1; rep <= cfg.repeat && ctx.Err() == nil; rep++ {
+		order := visitOrder(sessions, rep, shuffler, false)
+		if cfg.repeat > 1 {
+			log.Printf("Repetition %d/%d: %s", rep, cfg.repeat, targetNames(order))
+		}
+		for _, s := range order {
+			if ctx.Err() != nil || s.failed {
+				continue
+			}
+			for _, sc := range planned {
+				if err := s.runPlanned(ctx, sc, rep, emit); err != nil {
+					s.fail(err)
+					failed = true
+					break
+				}
+			}
+		}
+	}
+	return failed
+}
+
+// runInterleaved runs each planned scenario round-robin across the targets:
+// every concern setting and worker count, repetition by repetition, goes to
+// each target in turn before the next starts, so all of them run under the
+// same machine conditions. With -slices the targets take turns at each
+// slice of a run rather than at whole runs. The starting target rotates
+// with each round, or is shuffled with -shuffle, so none is always first.
+// It reports whether any target failed.
+func runInterleaved(ctx context.Context, cfg *runConfig, sessions []*targetSession, planned []plannedScenario, shuffler *rand.Rand, emit func(result) error) bool {
+	failed := false
+	for _, sc := range planned {
+		log.Printf("Interleaving %s across %s", sc.label(), targetNames(sessions))
+		for _, cs := range concernMatrix(cfg) {
+			for _, workers := range sc.workers {
+				for rep := 1; rep <= cfg.repeat && ctx.Err() == nil; rep++ {
+					if interleaveRun(ctx, sessions, sc, cs, workers, rep, shuffler, emit) {
+						failed = true
+					}
+				}
+			}
+		}
+	}
+	return failed
+}
+
+// interleaveRun runs one repetition of a scenario on every target, slice by
+// slice. A target's run is finished as soon as its last slice is measured,
+// or once the others are when the run is interrupted. It reports whether
+// any target failed.
+func interleaveRun(ctx context.Context, sessions []*targetSession, sc plannedScenario, cs concernSetting, workers, rep int, shuffler *rand.Rand, emit func(result) error) bool {
+	cfg := sessions[0].cfg
+	failed := false
+	finish := func(s *targetSession, run *scenarioRun) {
+		if err := s.finishRun(ctx, sc, cs, run, rep, emit); err != nil {
+			s.fail(err)
+			failed = true
+		}
+	}
+	runs := map[*targetSession]*scenarioRun{}
+	k := sc.slices(cfg)
+	for slice := 0; slice < k; slice++ {
+		n, duration := sc.sliceLimits(cfg, slice)
+		for _, s := range visitOrder(sessions, (rep-1)*k+slice+1, shuffler, true) {
+			if ctx.Err() != nil {
+				break
+			}
+			if s.failed || !s.runsOn(sc) {
+				continue
+			}
+			if runs[s] == nil {
+				run, err := s.startRun(ctx, sc, cs, workers)
+				if err != nil {
+					s.fail(err)
+					failed = true
+					continue
+				}
+				runs[s] = run
+			}
+			runs[s].measure(ctx, n, duration)
+			if slice == k-1 {
+				finish(s, runs[s])
+				delete(runs, s)
+			}
+		}
+	}
+	for _, s := range sessions {
+		if run := runs[s]; run != nil {
+			finish(s, run)
+		}
+	}
+	return failed
+}
+
+// visitOrder is the order of the targets in repetition rep: shuffled when
+// shuffler is set, otherwise as configured, rotated by rep-1 when rotate is
+// set.
+func visitOrder(sessions []*targetSession, rep int, shuffler *rand.Rand, rotate bool) []*targetSession {
+	order := slices.Clone(sessions)
+	switch {
+	case shuffler != nil:
+		shuffler.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	case rotate && len(order) > 0:
+		k := (rep - 1) % len(order)
+		order = append(order[k:], order[:k]...)
+	}
+	return order
+}
+
+func targetNames(sessions []*targetSession) string {
+	names := make([]string, len(sessions))
+	for i, s := range sessions {
+		names[i] = s.target.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// targetSession is a target the run is connected to, with what its
+// scenarios carry over from one run to the next.
+type targetSession struct {
+	cfg        *runConfig
+	target     Target
+	info       *serverInfo
+	pool       *envPool
+	stopMongod func()
+	// plans holds the plan of each query scenario by label, explained at
+	// the end of its first run that got through setup, before the teardown.
+	plans map[string]*queryPlan
+	// next is the first iteration number of each scenario's next run.
+	// Iteration numbers carry on across warmups, the concern matrix, worker
+	// sweep and repetitions so scenarios like InsertOne don't reuse ids.
+	next map[string]int
+	// skipped holds the labels of scenarios the topology rules out.
+	skipped map[string]bool
+	failed  bool
+}
+
+// openTarget launches the target if it has a Binary, connects to it and
+// checks the server is what the target declares.
+func openTarget(ctx context.Context, cfg *runConfig, target Target) (*targetSession, error) {
+	target, stopMongod, err := launchTarget(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	client, err := connect(target)
+	if err != nil {
+		stopMongod()
+		return nil, err
+	}
+	s := &targetSession{
+		cfg:        cfg,
+		target:     target,
+		pool:       &envPool{target: target, cfg: cfg, envs: []*runEnv{newRunEnv(client, cfg)}},
+		stopMongod: stopMongod,
+		plans:      map[string]*queryPlan{},
+		next:       map[string]int{},
+		skipped:    map[string]bool{},
+	}
+	log.Println("Connected to", target.Name)
+
+	if s.info, err = verifyTarget(ctx, client, target, cfg.versionCheck); err != nil {
+		s.close(ctx)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *targetSession) close(ctx context.Context) {
+	s.pool.close(ctx)
+	s.stopMongod()
+}
+
+// fail logs err and stops the session running anything else.
+func (s *targetSession) fail(err error) {
+	log.Printf("Target %s: %v", s.target.Name, err)
+	s.failed = true
+}
+
+// runsOn reports whether sc applies to the target, logging the first time
+// it doesn't.
+func (s *targetSession) runsOn(sc plannedScenario) bool {
+	if sc.runsOn(s.info) {
+		return true
+	}
+	if label := sc.label(); !s.skipped[label] {
+		s.skipped[label] = true
+		log.Printf("Skipping %s on %s: needs a %s deployment", label, s.target.Name, strings.Join(sc.topologies, " or "))
+	}
+	return false
+}
+
+// runPlanned runs one planned scenario over the concern matrix and its
+// worker counts.
+func (s *targetSession) runPlanned(ctx context.Context, sc plannedScenario, repeat int, emit func(result) error) error {
+	if !s.runsOn(sc) {
+		return nil
+	}
+	for _, cs := range concernMatrix(s.cfg) {
+		for _, workers := range sc.workers {
+			if err := s.runOnce(ctx, sc, cs, workers, repeat, emit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runOnce runs a planned scenario with one concern setting and worker count
+// and emits its result. repeat numbers the repetition when -repeat is above
+// 1.
+func (s *targetSession) runOnce(ctx context.Context, sc plannedScenario, cs concernSetting, workers, repeat int, emit func(result) error) error {
+	run, err := s.startRun(ctx, sc, cs, workers)
+	if err != nil {
+		return err
+	}
+	n, duration := sc.limits(s.cfg)
+	run.measure(ctx, n, duration)
+	return s.finishRun(ctx, sc, cs, run, repeat, emit)
+}
+
+// startRun prepares a run of a planned scenario with one concern setting
+// and worker count.
+func (s *targetSession) startRun(ctx context.Context, sc plannedScenario, cs concernSetting, workers int) (*scenarioRun, error) {
+	envs, err := s.pool.get(workers, cs)
+	if err != nil {
+		return nil, err
+	}
+	label := sc.label()
+	run := startRun(ctx, envs, sc.scenario, s.next[label])
+	_, explained := s.plans[label]
+	run.explain = sc.explain != nil && !explained
+	return run, nil
+}
+
+// finishRun finishes a run started by startRun and emits its result.
+func (s *targetSession) finishRun(ctx context.Context, sc plannedScenario, cs concernSetting, run *scenarioRun, repeat int, emit func(result) error) error {
+	cfg, target := s.cfg, s.target
+	label := sc.label()
+	workers := len(run.envs)
+	res, hist, next := run.finish(ctx)
+	s.next[label] = next
+	if run.explain && run.err == nil {
+		s.plans[label] = res.Plan
+	}
+	res.Plan = s.plans[label]
+	if res.Unsteady {
+		log.Printf("%s on %s with %d workers never reached steady throughput (CV %.2f over %s windows)",
+			label, target.Name, workers, res.ThroughputCV, cfg.steadyWindow)
+	}
+	res.RunID = cfg.runID
+	res.Target = target.Name
+	res.Server = s.info
+	res.Host = cfg.host
+	if cfg.repeat > 1 {
+		res.Repeat = repeat
+	}
+	for k, v := range cs.params() {
+		res.setParam(k, v)
+	}
+	if workers > 1 {
+		res.setParam("clients", cfg.clientMode)
+	}
+	if cfg.docgenPath != "" {
+		res.setParam("docgen", filepath.Base(cfg.docgenPath))
+		res.setParam("seed", strconv.FormatUint(cfg.gen.tmpl.Seed, 10))
+	}
+	if err := emit(res); err != nil {
+		return err
+	}
+	if cfg.histogramDir != "" {
+		name := fmt.Sprintf("%s-%s-w%d", target.Name, res.scenarioLabel(), workers)
+		if res.Repeat > 0 {
+			name += fmt.Sprintf("-r%d", res.Repeat)
+		}
+		if err := writeHistogram(cfg.histogramDir, name, hist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interval is the time between scheduled ops under -rate, 0 without it.
+func (cfg *runConfig) interval() time.Duration {
+	if cfg.rate <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / cfg.rate)
+}
+
+// envPool hands out one runEnv per worker, set up with the given concerns.
+// In shared mode every worker gets the target's first client; in per-worker
+// mode extra clients are connected on demand and kept for the rest of the
+// target's run.
+type envPool struct {
+	target Target
+	cfg    *runConfig
+	envs   []*runEnv
+}
+
+func (p *envPool) get(workers int, cs concernSetting) ([]*runEnv, error) {
+	envs := make([]*runEnv, workers)
+	for w := range envs {
+		if p.cfg.clientMode == clientsShared {
+			envs[w] = p.envs[0].withConcerns(cs)
+			continue
+		}
+		if w == len(p.envs) {
+			client, err := connect(p.target)
+			if err != nil {
+				return nil, err
+			}
+			p.envs = append(p.envs, newRunEnv(client, p.cfg))
+		}
+		envs[w] = p.envs[w].withConcerns(cs)
+	}
+	return envs, nil
+}
+
+func (p *envPool) close(ctx context.Context) {
+	for _, env := range p.envs {
+		if err := env.client.Disconnect(ctx); err != nil {
+			log.Println("Error disconnecting:", err)
+		}
+	}
+}
+
+func writeHistogram(dir, name string, hist *histogram) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dir, name+".hgrm"))
+	if err != nil {
+		return err
+	}
+	if err := hist.writePercentiles(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// phase is one stretch of iterations runPhase drives, the warmup or the
+// measurement.
+type phase struct {
+	// first is the iteration number of the phase's first op and n the most
+	// ops it runs.
+	first int
+	n     int
+	// duration, when set, stops the phase scheduling ops after that long.
+	duration time.Duration
+	// interval, when set, schedules op i of the phase at start + i*interval.
+	interval time.Duration
+	// stop, when set, ends the phase early once it is true.
+	stop *atomic.Bool
+	// completed, when set, counts finished ops for a throughputSampler.
+	completed *atomic.Int64
+}
+
+type workerStats struct {
+	hist     *histogram
+	ops      int
+	errors   int
+	firstErr error
+}
+
+// runPhase spreads the phase's iterations over one worker goroutine per env
+// and times each into its worker's histogram. Workers check whether the
+// phase is over before claiming an iteration, so the ones that ran are
+// always the phase's first ops and the next phase can start right after
+// them; only open-loop iterations scheduled past the deadline are claimed
+// and dropped, and those come after every other.
+//
+// With an interval the phase is open loop: iteration i is timed from its
+// scheduled start rather than from when a worker got to it, so a stall shows
+// up in the latency of every op queued behind it instead of being hidden by
+// the ops that were never sent.
+func runPhase(ctx context.Context, envs []*runEnv, sc scenario, p phase) ([]workerStats, time.Time, time.Duration) {
+	stats := make([]workerStats, len(envs))
+	var next atomic.Int64
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(p.duration)
+	for w, env := range envs {
+		stats[w].hist = newHistogram()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st := &stats[w]
+			for {
+				if ctx.Err() != nil || (p.stop != nil && p.stop.Load()) ||
+					(p.interval == 0 && p.duration > 0 && time.Now().After(deadline)) {
+					return
+				}
+				i := int(next.Add(1)) - 1
+				if i >= p.n {
+					return
+				}
+				opStart := time.Now()
+				if p.interval > 0 {
+					opStart = start.Add(time.Duration(i) * p.interval)
+					if p.duration > 0 && opStart.After(deadline) {
+						return
+					}
+				}
+				time.Sleep(time.Until(opStart))
+				err := sc.op(ctx, env, p.first+i)
+				st.hist.recordDuration(time.Since(opStart))
+				st.ops++
+				if p.completed != nil {
+					p.completed.Add(1)
+				}
+				if err != nil {
+					if st.errors == 0 {
+						st.firstErr = err
+					}
+					st.errors++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return stats, start, time.Since(start)
+}
+
+// runScenario seeds the scenario's dataset and runs its setup, warms it up
+// when -warmup is set, then runs its measured iterations from first until
+// its limits are reached, then its teardown. The returned next is the
+// iteration number after the last one the warmup and measurement used,
+// where the scenario's next run starts.
+func runScenario(ctx context.Context, envs []*runEnv, sc scenario, first int) (res result, hist *histogram, next int) {
+	run := startRun(ctx, envs, sc, first)
+	n, duration := sc.limits(envs[0].cfg)
+	run.measure(ctx, n, duration)
+	return run.finish(ctx)
+}
+
+// scenarioRun is one run of a scenario on a target. Its measured iterations
+// run in one phase or, under -slices, in several that other targets take
+// turns between; the run is seeded and warmed up once and its phases add up
+// to one result.
+//
+// Warmup ops take iteration numbers before the measured ones, and the
+// scenario is torn down and prepared again after them, so the measurement
+// sees the same data and server settings it would without a warmup. Batch
+// scenarios, which run a fixed count, aren't warmed up.
+type scenarioRun struct {
+	envs []*runEnv
+	sc   scenario
+	// err is why the run couldn't start, in which case nothing is measured.
+	err error
+	// next is the iteration number of the next measured op.
+	next   int
+	warmup *warmupSummary
+	// explain has finish capture the plan of the scenario's query after the
+	// measured phases, while the data and settings they ran with are still
+	// in place.
+	explain bool
+
+	// start is when the first phase started and elapsed the wall time of
+	// all of them, which throughput is measured over. rates are the
+	// -steady-window throughputs sampled during the phases, to tell whether
+	// throughput was steady. With -metrics-interval the server's metrics
+	// are sampled over the phases too.
+	start         time.Time
+	elapsed       time.Duration
+	rates         []float64
+	serverMetrics *serverMetrics
+	hist          *histogram
+	iterations    int
+	errors        int
+	firstErr      string
+}
+
+// startRun prepares the scenario and warms it up, leaving it ready for its
+// measured phases.
+func startRun(ctx context.Context, envs []*runEnv, sc scenario, first int) *scenarioRun {
+	run := &scenarioRun{envs: envs, sc: sc, next: first, hist: newHistogram()}
+	cfg := envs[0].cfg
+	if run.err = prepareScenario(ctx, envs[0], sc); run.err != nil {
+		return run
+	}
+	if cfg.warmup > 0 && !sc.once && sc.count == nil {
+		run.warmup = warmUp(ctx, envs, sc, first)
+		run.next += run.warmup.Ops
+		if run.err = finishScenario(ctx, envs[0], sc); run.err == nil {
+			run.err = prepareScenario(ctx, envs[0], sc)
+		}
+	}
+	return run
+}
+
+// measure runs up to n of the scenario's iterations, for at most duration
+// when that is set.
+func (run *scenarioRun) measure(ctx context.Context, n int, duration time.Duration) {
+	if run.err != nil {
+		return
+	}
+	cfg, sc := run.envs[0].cfg, run.sc
+	var metrics *metricsSampler
+	if cfg.metricsEvery > 0 {
+		var err error
+		if metrics, err = sampleServerMetrics(ctx, run.envs[0].client, sc.coll(run.envs[0]), cfg.metricsEvery); err != nil {
+			log.Printf("Error sampling server metrics for %s: %v", sc.name, err)
+		}
+	}
+	sampler := sampleThroughput(cfg.steadyWindow, nil)
+	stats, start, elapsed := runPhase(ctx, run.envs, sc, phase{
+		first:     run.next,
+		n:         n,
+		duration:  duration,
+		interval:  cfg.interval(),
+		completed: &sampler.completed,
+	})
+	run.rates = append(run.rates, sampler.stop()...)
+	if metrics != nil {
+		srv, err := metrics.stop(ctx)
+		if err != nil {
+			log.Printf("Error sampling server metrics for %s: %v", sc.name, err)
+		}
+		run.serverMetrics = run.serverMetrics.add(srv)
+	}
+	if run.start.IsZero() {
+		run.start = start
+	}
+	run.elapsed += elapsed
+	for _, st := range stats {
+		run.hist.merge(st.hist)
+		run.iterations += st.ops
+		run.next += st.ops
+		if st.errors > 0 && run.errors == 0 {
+			run.firstErr = st.firstErr.Error()
+		}
+		run.errors += st.errors
+	}
+}
+
+// finish runs the scenario's teardown, also when the run couldn't start so
+// a setup that got partway is undone, and sums its measured phases up. next
+// is where the scenario's next run starts.
+func (run *scenarioRun) finish(ctx context.Context) (res result, hist *histogram, next int) {
+	sc := run.sc
+	cfg := run.envs[0].cfg
+	var plan *queryPlan
+	if run.explain && run.err == nil {
+		var err error
+		if plan, err = explainScenario(ctx, run.envs[0], sc); err != nil {
+			log.Printf("Error explaining %s: %v", sc.label(), err)
+		}
+	}
+	teardownErr := finishScenario(ctx, run.envs[0], sc)
+	if run.err != nil {
+		res = result{Scenario: sc.name, Params: sc.params, Workers: len(run.envs), Errors: 1, FirstError: run.err.Error()}
+		if teardownErr != nil {
+			res.Errors++
+		}
+		return res, newHistogram(), run.next
+	}
+
+	res = result{
+		Timestamp:     run.start.UTC(),
+		Scenario:      sc.name,
+		Workers:       len(run.envs),
+		Plan:          plan,
+		Warmup:        run.warmup,
+		ServerMetrics: run.serverMetrics,
+		Iterations:    run.iterations,
+		Errors:        run.errors,
+		FirstError:    run.firstErr,
+	}
+	for k, v := range sc.params {
+		res.setParam(k, v)
+	}
+	if cfg.rate > 0 {
+		res.setParam("rate", strconv.FormatFloat(cfg.rate, 'f', -1, 64))
+	}
+
+	if teardownErr != nil {
+		if res.Errors == 0 {
+			res.FirstError = teardownErr.Error()
+		}
+		res.Errors++
+	}
+
+	if len(run.rates) >= steadyWindows {
+		res.ThroughputCV = cv(run.rates)
+	}
+	res.Unsteady = res.ThroughputCV > cfg.steadyCV || (run.warmup != nil && !run.warmup.Steady)
+
+	n, elapsed := res.Iterations, run.elapsed
+	res.DurationNs = elapsed.Nanoseconds()
+	if n > 0 {
+		res.NsPerOp = float64(res.DurationNs) / float64(n)
+	}
+	res.OpsPerSec = perSecond(n, elapsed)
+	if sc.docsPerOp != nil {
+		docs := sc.docsPerOp(run.envs[0])
+		res.DocsPerSec = perSecond(n*docs, elapsed)
+		res.setParam("docsPerOp", strconv.Itoa(docs))
+	}
+	if sc.bytesPerOp != nil {
+		res.MBPerSec = perSecond(n, elapsed) * sc.bytesPerOp(run.envs[0]) / 1e6
+	}
+	res.Latency = run.hist.summary()
+	return res, run.hist, run.next
+}
+
+// printScaling writes an ops/s table per scenario that was run with more than
+// one worker count, one row per target and one column per worker count.
+// Repeated runs are averaged.
+func printScaling(w io.Writer, results []result) {
+	type key struct{ scenario, target string }
+	curves := map[key]map[int][]float64{}
+	workerCounts := map[string][]int{}
+	var scenarioOrder, targetOrder []string
+	for _, res := range results {
+		label := res.scenarioLabel()
+		k := key{label, res.Target}
+		if curves[k] == nil {
+			curves[k] = map[int][]float64{}
+		}
+		curves[k][res.Workers] = append(curves[k][res.Workers], res.OpsPerSec)
+		if !slices.Contains(workerCounts[label], res.Workers) {
+			workerCounts[label] = append(workerCounts[label], res.Workers)
+		}
+		if !slices.Contains(scenarioOrder, label) {
+			scenarioOrder = append(scenarioOrder, label)
+		}
+		if !slices.Contains(targetOrder, res.Target) {
+			targetOrder = append(targetOrder, res.Target)
+		}
+	}
+
+	for _, sc := range scenarioOrder {
+		counts := workerCounts[sc]
+		if len(counts) < 2 {
+			continue
+		}
+		slices.Sort(counts)
+		fmt.Fprintf(w, "\n%s ops/s by workers\n%-12s", sc, "target")
+		for _, c := range counts {
+			fmt.Fprintf(w, " %12d", c)
+		}
+		fmt.Fprintln(w)
+		for _, t := range targetOrder {
+			curve, ok := curves[key{sc, t}]
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(w, "%-12s", t)
+			for _, c := range counts {
+				fmt.Fprintf(w, " %12.0f", mean(curve[c]))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// printBatchSweep writes the InsertManyBatched results as docs/s and MB/s per
+// batch size, one row per target, variant (ordered mode and any concerns) and
+// worker count. Repeated runs are averaged.
+func printBatchSweep(w io.Writer, results []result) {
+	type row struct {
+		target, variant string
+		workers         int
+	}
+	cells := map[row]map[int][]result{}
+	var rows []row
+	var sizes []int
+	for _, res := range results {
+		size, err := strconv.Atoi(res.Params["batchSize"])
+		if res.Scenario != "InsertManyBatched" || err != nil {
+			continue
+		}
+		r := row{res.Target, res.labelWith(nil, "batchSize", "docsPerOp", "clients"), res.Workers}
+		if cells[r] == nil {
+			cells[r] = map[int][]result{}
+			rows = append(rows, r)
+		}
+		cells[r][size] = append(cells[r][size], res)
+		if !slices.Contains(sizes, size) {
+			sizes = append(sizes, size)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+	slices.Sort(sizes)
+
+	fmt.Fprintf(w, "\nInsertManyBatched docs/s (MB/s) by batch size\n%-12s %-40s %-8s", "target", "variant", "workers")
+	for _, size := range sizes {
+		fmt.Fprintf(w, " %20d", size)
+	}
+	fmt.Fprintln(w)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%-12s %-40s %-8d", r.target, r.variant, r.workers)
+		for _, size := range sizes {
+			runs := cells[r][size]
+			if len(runs) == 0 {
+				fmt.Fprintf(w, " %20s", "-")
+				continue
+			}
+			var docs, mb []float64
+			for _, res := range runs {
+				docs = append(docs, res.DocsPerSec)
+				mb = append(mb, res.MBPerSec)
+			}
+			fmt.Fprintf(w, " %20s", fmt.Sprintf("%.0f (%.1f)", mean(docs), mean(mb)))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func perSecond(count int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// offlineEnv is a runEnv whose client never connects, for scenarios that
+// don't touch the server.
+func offlineEnv(t *testing.T, cfg *runConfig) *runEnv {
+	t.Helper()
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	return newRunEnv(client, cfg)
+}
+
+func TestScenarioLimits(t *testing.T) {
+	cfg := defaultRunConfig()
+	plain := scenario{name: "Plain"}
+	once := scenario{name: "Once", once: true}
+	mix := scenario{name: "Mix", duration: time.Minute}
+
+	cfg.duration = 10 * time.Second
+	for _, c := range []struct {
+		sc       scenario
+		opLimit  int
+		n        int
+		duration time.Duration
+	}{
+		{plain, 0, math.MaxInt, 10 * time.Second},
+		{plain, 500, 500, 10 * time.Second},
+		{once, 0, 1, 0},
+		{mix, 500, math.MaxInt, time.Minute},
+	} {
+		cfg.opLimit = c.opLimit
+		if n, d := c.sc.limits(cfg); n != c.n || d != c.duration {
+			t.Errorf("%s with -n %d: limits %d, %s; want %d, %s", c.sc.name, c.opLimit, n, d, c.n, c.duration)
+		}
+	}
+
+	cfg.slices = 3
+	cfg.opLimit = 10
+	var total int
+	for slice := range 3 {
+		n, d := plain.sliceLimits(cfg, slice)
+		if d != 10*time.Second/3 {
+			t.Errorf("slice %d runs for %s", slice, d)
+		}
+		total += n
+	}
+	if total != 10 {
+		t.Errorf("slices of -n 10 add up to %d iterations", total)
+	}
+	if n, _ := once.sliceLimits(cfg, 0); n != 1 {
+		t.Errorf("once scenario sliced to %d iterations", n)
+	}
+}
+
+func TestRunScenarioForDurationAtRate(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.duration = 50 * time.Millisecond
+	cfg.rate = 1000
+	sc := scenario{name: "Noop", concurrent: true, op: func(ctx context.Context, env *runEnv, i int) error {
+		return nil
+	}}
+	env := offlineEnv(t, cfg)
+
+	res, _, _ := runScenario(context.Background(), []*runEnv{env, env}, sc, 0)
+	// Ops are scheduled every millisecond and the last one at the deadline
+	// still runs.
+	if res.Iterations != 51 || res.Errors != 0 {
+		t.Errorf("ran %d iterations with %d errors, want 51 and none", res.Iterations, res.Errors)
+	}
+	if res.Params["rate"] != "1000" {
+		t.Errorf("params %v lack the rate", res.Params)
+	}
+}
+
+func TestRunScenarioRateCountsQueuedTime(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.iterations = 20
+	cfg.rate = 200
+	sc := scenario{name: "Stall", op: func(ctx context.Context, env *runEnv, i int) error {
+		if i == 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return nil
+	}}
+
+	res, _, _ := runScenario(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	// Every op queued behind the stall was late by up to 95ms; a closed-loop
+	// measurement would put the median near zero.
+	if res.Latency == nil || time.Duration(res.Latency.P50) < 30*time.Millisecond {
+		t.Errorf("median latency %v doesn't include the time ops waited behind the stall", res.Latency)
+	}
+}
+
+func TestVisitOrder(t *testing.T) {
+	sessions := []*targetSession{{target: Target{Name: "a"}}, {target: Target{Name: "b"}}, {target: Target{Name: "c"}}}
+	for rep, want := range map[int]string{1: "a, b, c", 2: "b, c, a", 4: "a, b, c"} {
+		if got := targetNames(visitOrder(sessions, rep, nil, true)); got != want {
+			t.Errorf("repetition %d visits %s, want %s", rep, got, want)
+		}
+	}
+	if got := targetNames(visitOrder(sessions, 2, nil, false)); got != "a, b, c" {
+		t.Errorf("without rotation visits %s", got)
+	}
+}
+
+// offlineSessions are standalone targets whose envs never connect.
+func offlineSessions(t *testing.T, cfg *runConfig, names ...string) []*targetSession {
+	var sessions []*targetSession
+	for _, name := range names {
+		sessions = append(sessions, &targetSession{
+			cfg:     cfg,
+			target:  Target{Name: name},
+			info:    &serverInfo{Topology: topologyStandalone},
+			pool:    &envPool{cfg: cfg, envs: []*runEnv{offlineEnv(t, cfg)}},
+			plans:   map[string]*queryPlan{},
+			next:    map[string]int{},
+			skipped: map[string]bool{},
+		})
+	}
+	return sessions
+}
+
+func TestRunInterleaved(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.iterations = 3
+	cfg.repeat = 2
+	sessions := offlineSessions(t, cfg, "a", "b")
+	noop := func(ctx context.Context, env *runEnv, i int) error { return nil }
+	planned := []plannedScenario{
+		{scenario: scenario{name: "First", op: noop}, workers: []int{1}},
+		{scenario: scenario{name: "Second", concurrent: true, op: noop}, workers: []int{1, 2}},
+	}
+
+	var got []string
+	failed := runInterleaved(context.Background(), cfg, sessions, planned, nil, func(res result) error {
+		got = append(got, fmt.Sprintf("%s/w%d/r%d@%s", res.Scenario, res.Workers, res.Repeat, res.Target))
+		return nil
+	})
+	want := []string{
+		"First/w1/r1@a", "First/w1/r1@b", "First/w1/r2@b", "First/w1/r2@a",
+		"Second/w1/r1@a", "Second/w1/r1@b", "Second/w1/r2@b", "Second/w1/r2@a",
+		"Second/w2/r1@a", "Second/w2/r1@b", "Second/w2/r2@b", "Second/w2/r2@a",
+	}
+	if failed || !slices.Equal(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+	if sessions[0].next["Second"] != 12 {
+		t.Errorf("Second's next iteration on a is %d, want 12", sessions[0].next["Second"])
+	}
+}
+
+func TestRunInterleavedSlices(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.iterations = 10
+	cfg.interleave = true
+	cfg.slices = 3
+	sessions := offlineSessions(t, cfg, "a", "b")
+	targetOf := map[*mongo.Client]string{}
+	for _, s := range sessions {
+		targetOf[s.pool.envs[0].client] = s.target.Name
+	}
+	var ops []string
+	record := func(ctx context.Context, env *runEnv, i int) error {
+		ops = append(ops, fmt.Sprintf("%s%d", targetOf[env.client], i))
+		return nil
+	}
+	noop := func(ctx context.Context, env *runEnv, i int) error { return nil }
+	planned := []plannedScenario{
+		{scenario: scenario{name: "Sliced", op: record}, workers: []int{1}},
+		{scenario: scenario{name: "Once", once: true, op: noop}, workers: []int{1}},
+	}
+
+	var got []string
+	failed := runInterleaved(context.Background(), cfg, sessions, planned, nil, func(res result) error {
+		got = append(got, fmt.Sprintf("%s/n%d@%s", res.Scenario, res.Iterations, res.Target))
+		return nil
+	})
+	want := []string{"Sliced/n10@a", "Sliced/n10@b", "Once/n1@a", "Once/n1@b"}
+	if failed || !slices.Equal(got, want) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+	// Slices of 3, 3 and 4 iterations, with the first target rotating.
+	want = strings.Fields("a0 a1 a2 b0 b1 b2 b3 b4 b5 a3 a4 a5 a6 a7 a8 a9 b6 b7 b8 b9")
+	if !slices.Equal(ops, want) {
+		t.Errorf("ran ops %q, want %q", ops, want)
+	}
+	if sessions[1].next["Sliced"] != 10 {
+		t.Errorf("Sliced's next iteration on b is %d, want 10", sessions[1].next["Sliced"])
+	}
+}
+
+func TestScenarioRunExplainsBeforeTeardown(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.iterations = 1
+	var calls []string
+	sc := scenario{
+		name: "Query",
+		op:   func(ctx context.Context, env *runEnv, i int) error { return nil },
+		explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+			calls = append(calls, "explain")
+			return env.coll, findCommand(env.coll, bson.M{}, 1)
+		},
+		teardown: func(ctx context.Context, env *runEnv) error {
+			calls = append(calls, "teardown")
+			return nil
+		},
+	}
+
+	// Cancelling before finish makes the explain command itself fail at
+	// once rather than wait for a server.
+	ctx, cancel := context.WithCancel(context.Background())
+	run := startRun(ctx, []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	run.explain = true
+	run.measure(ctx, 1, 0)
+	cancel()
+	run.finish(ctx)
+	if want := []string{"explain", "teardown"}; !slices.Equal(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+}
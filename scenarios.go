@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+	"go.mongodb.org/mongo-driver/v2/mongo/writeconcern"
+
+	"mongoVersionSpeedTest/workload"
+)
+
+// runEnv is what a scenario operates on for one target.
+type runEnv struct {
+	client *mongo.Client
+	gen    *docGenerator
+	coll   *mongo.Collection
+	gridFS *mongo.Database
+	cfg    *runConfig
+	// workload is the same environment for registered scenarios.
+	workload *workload.Env
+}
+
+// scenario is a named benchmark operation. One call to op is one iteration,
+// driven by b.N under go test and by -n in the run command; i counts
+// iterations from 0. once scenarios are batch operations that the run command
+// performs a single time regardless of -n, and count, when set, replaces -n
+// altogether; duration, when set, has the run command call op until that much
+// time has passed instead. concurrent scenarios may have op called from several workers
+// at once, each with a distinct i. dataset is the data the scenario measures
+// against, seeded by the runner; setup then runs before the timed iterations
+// and teardown after them, both untimed.
+// docsPerOp and bytesPerOp, when set, report how many documents and bytes one
+// iteration writes or reads so throughput can be given in docs/s and MB/s.
+// topologies, when set, lists the deployments the scenario means anything
+// on; it is skipped on the others. explain, set on queries, returns the
+// collection and the find or aggregate command of a representative
+// iteration so the runner can capture its plan, with explainReadPref, when
+// set, as the read preference it runs with. namespace, set on scenarios
+// that work outside their dataset's collection, returns the collection
+// their server metrics sample dbStats and $collStats on.
+//
+// A scenario with variants stands for the family of scenarios it returns,
+// each labelled with the params that distinguish it; it is concurrent if any
+// of them is.
+type scenario struct {
+	name       string
+	params     map[string]string
+	once       bool
+	count      func(cfg *runConfig) int
+	duration   time.Duration
+	concurrent bool
+	dataset    dataset
+	setup      func(ctx context.Context, env *runEnv) error
+	teardown   func(ctx context.Context, env *runEnv) error
+	op         func(ctx context.Context, env *runEnv, i int) error
+	docsPerOp  func(env *runEnv) int
+	bytesPerOp func(env *runEnv) float64
+	variants   func(cfg *runConfig) []scenario
+	topologies []string
+	explain    func(env *runEnv) (*mongo.Collection, bson.D)
+	// explainReadPref is needed because the driver runs commands on the
+	// primary whatever the read preference of the collection explain returns.
+	explainReadPref *readpref.ReadPref
+	namespace       func(env *runEnv) *mongo.Collection
+}
+
+// coll is the collection the scenario works on: its namespace, or else its
+// dataset's.
+func (sc scenario) coll(env *runEnv) *mongo.Collection {
+	if sc.namespace != nil {
+		return sc.namespace(env)
+	}
+	coll, _, _ := sc.dataset.source(env)
+	return coll
+}
+
+// iterations is how many times the run command calls op.
+func (sc scenario) iterations(cfg *runConfig) int {
+	switch {
+	case sc.count != nil:
+		return sc.count(cfg)
+	case sc.once:
+		return 1
+	}
+	return cfg.iterations
+}
+
+// limits is how many times and for how long the run command calls op. A
+// scenario's own duration comes first; counted and once scenarios ignore
+// -duration, the others run for it, stopping early at -n only when that was
+// given too.
+func (sc scenario) limits(cfg *runConfig) (int, time.Duration) {
+	switch {
+	case sc.duration > 0:
+		return math.MaxInt, sc.duration
+	case sc.count != nil || sc.once || cfg.duration == 0:
+		return sc.iterations(cfg), 0
+	case cfg.opLimit > 0:
+		return cfg.opLimit, cfg.duration
+	}
+	return math.MaxInt, cfg.duration
+}
+
+// slices is how many slices -slices splits a run of the scenario into.
+// Counted and once scenarios always run whole.
+func (sc scenario) slices(cfg *runConfig) int {
+	if sc.count != nil || sc.once {
+		return 1
+	}
+	return max(cfg.slices, 1)
+}
+
+// sliceLimits is the share of the scenario's limits slice, counted from 0,
+// of a run split by -slices gets: an equal part of its duration, and of its
+// iterations spread so the slices add up to them.
+func (sc scenario) sliceLimits(cfg *runConfig, slice int) (int, time.Duration) {
+	n, duration := sc.limits(cfg)
+	k := sc.slices(cfg)
+	if n != math.MaxInt {
+		n = n*(slice+1)/k - n*slice/k
+	}
+	return n, duration / time.Duration(k)
+}
+
+// label names the scenario together with its params, e.g.
+// InsertManyBatched[batchSize=100,ordered=true].
+func (sc scenario) label() string {
+	r := result{Scenario: sc.name, Params: sc.params}
+	return r.scenarioLabel()
+}
+
+// runsOn reports whether the scenario applies to the server's topology.
+func (sc scenario) runsOn(info *serverInfo) bool {
+	return sc.topologies == nil || slices.Contains(sc.topologies, info.Topology)
+}
+
+// plannedScenario is a scenario with the worker counts the run command
+// sweeps it over.
+type plannedScenario struct {
+	scenario
+	workers []int
+}
+
+func newRunEnv(client *mongo.Client, cfg *runConfig) *runEnv {
+	env := &runEnv{
+		client: client,
+		gen:    cfg.gen,
+		coll:   client.Database(cfg.database).Collection(cfg.collection),
+		gridFS: client.Database(cfg.gridFSDatabase),
+		cfg:    cfg,
+	}
+	env.workload = env.workloadEnv()
+	return env
+}
+
+func (env *runEnv) workloadEnv() *workload.Env {
+	return &workload.Env{
+		Client:     env.client,
+		Database:   env.coll.Database(),
+		Collection: env.coll,
+		Iterations: env.cfg.iterations,
+		Seed:       env.gen.tmpl.Seed,
+	}
+}
+
+// withConcerns returns a copy of env whose collection and GridFS database use
+// the given write/read concern and read preference.
+func (env *runEnv) withConcerns(cs concernSetting) *runEnv {
+	c := *env
+	c.coll = cs.database(env.client, env.cfg.database).Collection(env.cfg.collection)
+	c.gridFS = cs.database(env.client, env.cfg.gridFSDatabase)
+	c.workload = c.workloadEnv()
+	return &c
+}
+
+// indexColl is the index suite's collection. It inherits the run's concerns.
+func (env *runEnv) indexColl() *mongo.Collection {
+	return env.coll.Database().Collection(env.cfg.collection + "Indexed")
+}
+
+// aggColl is the aggregation suite's collection.
+func (env *runEnv) aggColl() *mongo.Collection {
+	return env.coll.Database().Collection(env.cfg.collection + "Aggregated")
+}
+
+// categoryColl is the small collection the aggregation suite's $lookup joins.
+func (env *runEnv) categoryColl() *mongo.Collection {
+	return env.coll.Database().Collection(env.cfg.collection + "Categories")
+}
+
+// gridFSChunks is the chunks collection of the GridFS scenarios' bucket,
+// where their data lives.
+func (env *runEnv) gridFSChunks() *mongo.Collection {
+	return env.gridFS.Collection("fs.chunks")
+}
+
+// majorityColl is a collection of its own written with w:majority, whatever
+// write concern the run uses.
+func (env *runEnv) majorityColl() *mongo.Collection {
+	opts := options.Collection().SetWriteConcern(writeconcern.Majority())
+	return env.coll.Database().Collection(env.cfg.collection+"Majority", opts)
+}
+
+// secondaryColl is the main collection read from secondaries only.
+func (env *runEnv) secondaryColl() *mongo.Collection {
+	return env.coll.Database().Collection(env.cfg.collection, options.Collection().SetReadPreference(readpref.Secondary()))
+}
+
+// shardedColl holds a copy of the InsertMany documents sharded on a hashed _id.
+func (env *runEnv) shardedColl() *mongo.Collection {
+	return env.coll.Database().Collection(env.cfg.collection + "Sharded")
+}
+
+// setupShardedColl creates and fills shardedColl unless an earlier run
+// already did.
+func setupShardedColl(ctx context.Context, env *runEnv) error {
+	coll := env.shardedColl()
+	if n, err := coll.EstimatedDocumentCount(ctx); err == nil && n == int64(env.cfg.docs) {
+		return nil
+	}
+	if err := dropCollection(ctx, coll); err != nil {
+		return err
+	}
+	if err := shardCollection(ctx, coll); err != nil {
+		return err
+	}
+	for first := 1; first <= env.cfg.docs; first += seedBatch {
+		if err := insertMany(ctx, coll, env.gen.docs(first, min(seedBatch, env.cfg.docs-first+1)), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// docID maps an iteration onto one of the documents inserted by InsertMany.
+func (env *runEnv) docID(i int) any {
+	return env.gen.id(i%env.cfg.docs + 1)
+}
+
+// decodeTarget returns what FindOneByIdWithDeserialization decodes into:
+// myFile for the default documents, a generic map for custom templates.
+func (env *runEnv) decodeTarget() any {
+	if env.gen.isDefault() {
+		return &myFile{}
+	}
+	return &bson.M{}
+}
+
+// explainFindById and explainFindUpdated explain the queries of the
+// FindOneById and FindManyUsingIndex scenarios.
+func explainFindById(env *runEnv) (*mongo.Collection, bson.D) {
+	return env.coll, findCommand(env.coll, bson.M{"_id": env.docID(0)}, 1)
+}
+
+func explainFindUpdated(env *runEnv) (*mongo.Collection, bson.D) {
+	return env.coll, findCommand(env.coll, bson.M{"updated": true}, 0)
+}
+
+// The datasets the collection scenarios share.
+var (
+	emptyColl   = dataset{collection: collectionEmpty}
+	seededColl  = dataset{collection: collectionSeeded}
+	updatedColl = dataset{collection: collectionSeeded, updated: true}
+	indexedColl = dataset{collection: collectionSeeded, updated: true, indexes: []indexSpec{updatedIndex}}
+	gridFSFile  = dataset{gridFSFile: true}
+)
+
+var scenarios = []scenario{
+	{name: "InsertOne", concurrent: true, dataset: emptyColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return insertOne(ctx, env.coll, env.gen.doc(i+1))
+	}},
+	{name: "InsertManyBatched", concurrent: true, variants: insertManyBatchedVariants},
+	insertManyScenario(),
+	{name: "UpdateOne", concurrent: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return updateOne(ctx, env.coll, env.docID(i))
+	}},
+	{name: "UpdateMany", once: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return updateMany(ctx, env.coll)
+	}},
+	{name: "DeleteOne", dataset: updatedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return deleteOne(ctx, env.coll)
+	}},
+	{name: "DeleteMany", once: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return deleteMany(ctx, env.coll)
+	}},
+	{name: "FindOne", dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findOne(ctx, env.coll)
+	}, explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+		return env.coll, findCommand(env.coll, bson.M{}, 1)
+	}},
+	{name: "FindOneByIdWithoutDeserialization", concurrent: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findOneById(ctx, env.coll, env.docID(i), nil)
+	}, explain: explainFindById},
+	{name: "FindOneByIdWithDeserialization", concurrent: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findOneById(ctx, env.coll, env.docID(i), env.decodeTarget())
+	}, explain: explainFindById},
+	{name: "CreateIndex", once: true, dataset: updatedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return createIndex(ctx, env.coll, updatedIndex)
+	}},
+	{name: "FindManyUsingIndexWithoutDeserialization", dataset: indexedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findManyUsingIndex(ctx, env.coll, nil)
+	}, explain: explainFindUpdated},
+	{name: "FindManyUsingIndexWithDeserialization", dataset: indexedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findManyUsingIndex(ctx, env.coll, env.decodeTarget)
+	}, explain: explainFindUpdated},
+	{name: "FindAll", dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findAll(ctx, env.coll)
+	}, explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+		return env.coll, findCommand(env.coll, bson.M{}, 0)
+	}},
+	{name: "DropCollection", once: true, dataset: seededColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return dropCollection(ctx, env.coll)
+	}},
+	{name: "IndexBuild", variants: indexBuildVariants},
+	{name: "IndexedInsert", concurrent: true, variants: indexedInsertVariants},
+	{name: "IndexedUpdate", concurrent: true, variants: indexedUpdateVariants},
+	{name: "IndexedQuery", concurrent: true, variants: indexedQueryVariants},
+	{name: "AggMatchGroup", concurrent: true, variants: aggMatchGroupVariants},
+	{name: "AggLookup", concurrent: true, variants: aggLookupVariants},
+	{name: "AggUnwind", concurrent: true, variants: aggUnwindVariants},
+	{name: "AggSortSpill", variants: aggSortSpillVariants},
+	{name: "AggFacet", concurrent: true, variants: aggFacetVariants},
+	{name: "AggBucket", concurrent: true, variants: aggBucketVariants},
+	{name: "AggWindow", concurrent: true, variants: aggWindowVariants},
+	{name: "MajorityInsertOne", concurrent: true, topologies: []string{topologyReplicaSet, topologySharded}, setup: func(ctx context.Context, env *runEnv) error {
+		return dropCollection(ctx, env.majorityColl())
+	}, op: func(ctx context.Context, env *runEnv, i int) error {
+		return insertOne(ctx, env.majorityColl(), env.gen.doc(i+1))
+	}, teardown: func(ctx context.Context, env *runEnv) error {
+		return dropCollection(ctx, env.majorityColl())
+	}, namespace: (*runEnv).majorityColl},
+	{name: "SecondaryFindOneById", concurrent: true, dataset: seededColl, topologies: []string{topologyReplicaSet, topologySharded}, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findOneById(ctx, env.secondaryColl(), env.docID(i), nil)
+	}, explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+		return env.secondaryColl(), findCommand(env.secondaryColl(), bson.M{"_id": env.docID(0)}, 1)
+	}, explainReadPref: readpref.Secondary()},
+	{name: "ScatterGatherFind", concurrent: true, topologies: []string{topologySharded}, setup: setupShardedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findFirst(ctx, env.shardedColl(), bson.M{}, 100)
+	}, explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+		return env.shardedColl(), findCommand(env.shardedColl(), bson.M{}, 100)
+	}, namespace: (*runEnv).shardedColl},
+	{name: "ShardTargetedFindOneById", concurrent: true, topologies: []string{topologySharded}, setup: setupShardedColl, op: func(ctx context.Context, env *runEnv, i int) error {
+		return findOneById(ctx, env.shardedColl(), env.docID(i), nil)
+	}, explain: func(env *runEnv) (*mongo.Collection, bson.D) {
+		return env.shardedColl(), findCommand(env.shardedColl(), bson.M{"_id": env.docID(0)}, 1)
+	}, namespace: (*runEnv).shardedColl},
+	{name: "GridFSUploadFromStream", concurrent: true, op: func(ctx context.Context, env *runEnv, i int) error {
+		return gridFSUploadFromStream(ctx, env.gridFS, env.cfg.filePath)
+	}, namespace: (*runEnv).gridFSChunks},
+	{name: "GridFSOpenUploadStream", concurrent: true, op: func(ctx context.Context, env *runEnv, i int) error {
+		return gridFSOpenUploadStream(ctx, env.gridFS, env.cfg.filePath)
+	}, namespace: (*runEnv).gridFSChunks},
+	{name: "GridFSDownloadToStream", concurrent: true, dataset: gridFSFile, op: func(ctx context.Context, env *runEnv, i int) error {
+		return gridFSDownloadToStream(ctx, env.gridFS)
+	}, namespace: (*runEnv).gridFSChunks},
+	{name: "GridFSOpenDownloadStream", concurrent: true, dataset: gridFSFile, op: func(ctx context.Context, env *runEnv, i int) error {
+		return gridFSOpenDownloadStream(ctx, env.gridFS)
+	}, namespace: (*runEnv).gridFSChunks},
+	{name: "GridFSDrop", once: true, dataset: gridFSFile, op: func(ctx context.Context, env *runEnv, i int) error {
+		return gridFSDrop(ctx, env.gridFS)
+	}, namespace: (*runEnv).gridFSChunks},
+}
+
+// fromWorkload adapts a registered scenario to the runner's.
+//
+// The built-in scenarios stay in the table above rather than registering
+// through the workload package: they depend on the runner's own
+// environment (document generator, GridFS bucket, flags), declare datasets
+// the runner seeds and explain commands it captures, and expand into
+// variants per flag setting, none of which a third-party scenario needs.
+// What does matter to both, topologies, fixed iteration counts and the
+// collection worked on, a registered scenario declares through the optional
+// interfaces.
+func fromWorkload(w workload.Scenario) scenario {
+	c, ok := w.(workload.Concurrent)
+	sc := scenario{
+		name:       w.Name(),
+		params:     w.Params(),
+		concurrent: ok && c.Concurrent(),
+		setup: func(ctx context.Context, env *runEnv) error {
+			return w.Setup(ctx, env.workload)
+		},
+		op: func(ctx context.Context, env *runEnv, i int) error {
+			return w.Op(ctx, env.workload, i)
+		},
+		teardown: func(ctx context.Context, env *runEnv) error {
+			return w.Teardown(ctx, env.workload)
+		},
+	}
+	if t, ok := w.(workload.Topologies); ok {
+		sc.topologies = t.Topologies()
+	}
+	if ns, ok := w.(workload.Namespace); ok {
+		sc.namespace = func(env *runEnv) *mongo.Collection {
+			return ns.Namespace(env.workload)
+		}
+	}
+	if c, ok := w.(workload.Counted); ok {
+		n := c.Count()
+		sc.count = func(cfg *runConfig) int { return n }
+	}
+	return sc
+}
+
+// insertManyScenario inserts -docs documents in one InsertMany. Setup
+// generates them, documents 1..-docs, so the timed op only sends them, as
+// the go test benchmark it replaces did by resetting its timer after
+// building the slice. The batch is kept per target client until the op
+// takes it; further iterations, which only go test's b.N asks for, generate
+// their own fresh id range.
+func insertManyScenario() scenario {
+	batches := map[*mongo.Client][]any{}
+	return scenario{
+		name:    "InsertMany",
+		once:    true,
+		dataset: emptyColl,
+		setup: func(ctx context.Context, env *runEnv) error {
+			batches[env.client] = env.gen.docs(1, env.cfg.docs)
+			return nil
+		},
+		op: func(ctx context.Context, env *runEnv, i int) error {
+			docs, ok := batches[env.client]
+			delete(batches, env.client)
+			if !ok {
+				docs = env.gen.docs(i*env.cfg.docs+1, env.cfg.docs)
+			}
+			return insertMany(ctx, env.coll, docs, true)
+		},
+		teardown: func(ctx context.Context, env *runEnv) error {
+			delete(batches, env.client)
+			return nil
+		},
+		docsPerOp: func(env *runEnv) int {
+			return env.cfg.docs
+		},
+	}
+}
+
+// insertManyBatchedVariants sweeps InsertMany over -batch-sizes and -ordered.
+// Each variant starts from an empty collection and inserts -batch-docs
+// documents, rounded up to whole batches, one batch per iteration.
+func insertManyBatchedVariants(cfg *runConfig) []scenario {
+	var variants []scenario
+	for _, batch := range cfg.batchSizes {
+		for _, ordered := range cfg.orderedModes {
+			variants = append(variants, scenario{
+				name: "InsertManyBatched",
+				params: map[string]string{
+					"batchSize": strconv.Itoa(batch),
+					"ordered":   strconv.FormatBool(ordered),
+				},
+				count: func(cfg *runConfig) int {
+					return (cfg.batchDocs + batch - 1) / batch
+				},
+				concurrent: true,
+				dataset:    emptyColl,
+				op: func(ctx context.Context, env *runEnv, i int) error {
+					return insertMany(ctx, env.coll, env.gen.docs(i*batch+1, batch), ordered)
+				},
+				docsPerOp: func(env *runEnv) int {
+					return batch
+				},
+				bytesPerOp: func(env *runEnv) float64 {
+					return float64(batch) * env.gen.avgDocSize()
+				},
+			})
+		}
+	}
+	return variants
+}
+
+// defaultScenarios is the suite fBenchmarkTargets runs, and the run command
+// runs when -scenarios is not given. Every scenario seeds its own dataset, so
+// the order only decides how often data has to be reseeded.
+var defaultScenarios = []string{
+	"InsertMany",
+	"UpdateOne",
+	"FindOneByIdWithoutDeserialization",
+	"FindOneByIdWithDeserialization",
+	"CreateIndex",
+	"FindManyUsingIndexWithoutDeserialization",
+	"FindManyUsingIndexWithDeserialization",
+	"DropCollection",
+	"GridFSUploadFromStream",
+	"GridFSDownloadToStream",
+	"GridFSDrop",
+}
+
+// lookupScenarios returns the built-in and registered scenarios whose name
+// matches pattern, either a name or a glob such as Agg* or Find*ById*, in
+// table then registration order.
+func lookupScenarios(pattern string) ([]scenario, error) {
+	var found []scenario
+	for _, sc := range scenarios {
+		ok, err := workload.MatchName(pattern, sc.name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, sc)
+		}
+	}
+	registered, err := workload.Match(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range registered {
+		if slices.ContainsFunc(scenarios, func(sc scenario) bool { return strings.EqualFold(sc.name, w.Name()) }) {
+			return nil, fmt.Errorf("registered scenario %s has the name of a built-in one", w.Name())
+		}
+		found = append(found, fromWorkload(w))
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("unknown scenario %q", pattern)
+	}
+	return found, nil
+}
+
+// planScenarios resolves -scenarios entries of the form Pattern or
+// Pattern@w1/w2/..., where Pattern is a scenario name or glob and the @
+// suffix overrides the default worker counts for the scenarios it selects.
+// Scenarios that can't run concurrently get a single worker unless a count
+// was asked for explicitly by name, which is an error.
+func planScenarios(cfg *runConfig) ([]plannedScenario, error) {
+	var planned []plannedScenario
+	for _, spec := range cfg.scenarios {
+		pattern, counts, hasCounts := strings.Cut(spec, "@")
+		found, err := lookupScenarios(pattern)
+		if err != nil {
+			return nil, err
+		}
+		workers := cfg.workers
+		if hasCounts {
+			if workers, err = parseCounts(strings.Split(counts, "/")); err != nil {
+				return nil, fmt.Errorf("scenario %s: %w", spec, err)
+			}
+		}
+		byName := !strings.ContainsAny(pattern, "*?[")
+
+		for _, sc := range found {
+			if !sc.concurrent && byName && hasCounts && (len(workers) != 1 || workers[0] != 1) {
+				return nil, fmt.Errorf("scenario %s can't run with several workers", sc.name)
+			}
+			if sc.variants == nil {
+				planned = append(planned, plannedScenario{scenario: sc, workers: scenarioWorkers(sc, workers)})
+				continue
+			}
+			for _, v := range sc.variants(cfg) {
+				planned = append(planned, plannedScenario{scenario: v, workers: scenarioWorkers(v, workers)})
+			}
+		}
+	}
+	for _, m := range cfg.mixes {
+		workers := cfg.workers
+		if m.Workers > 0 {
+			workers = []int{m.Workers}
+		}
+		planned = append(planned, plannedScenario{scenario: m.scenario(), workers: workers})
+	}
+	return planned, nil
+}
+
+// scenarioWorkers is the worker counts sc runs with: workers if it is
+// concurrent, otherwise just one.
+func scenarioWorkers(sc scenario, workers []int) []int {
+	if !sc.concurrent {
+		return []int{1}
+	}
+	return workers
+}
+
+func parseBools(items []string) ([]bool, error) {
+	values := make([]bool, 0, len(items))
+	for _, item := range items {
+		v, err := strconv.ParseBool(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func parseCounts(items []string) ([]int, error) {
+	counts := make([]int, 0, len(items))
+	for _, item := range items {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid count %q", item)
+		}
+		counts = append(counts, n)
+	}
+	return counts, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
+
+	"mongoVersionSpeedTest/workload"
+)
+
+func TestPlanScenarios(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.scenarios = []string{"InsertMany", "UpdateOne", "FindOneByIdWithDeserialization@2/8"}
+	cfg.workers = []int{1, 4}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{1}, {1, 4}, {2, 8}}
+	for i, p := range planned {
+		if !slices.Equal(p.workers, want[i]) {
+			t.Errorf("%s: workers %v, want %v", p.name, p.workers, want[i])
+		}
+	}
+
+	cfg.scenarios = []string{"InsertMany@4"}
+	if _, err := planScenarios(cfg); err == nil {
+		t.Error("expected error for a non-concurrent scenario with several workers")
+	}
+	cfg.scenarios = []string{"UpdateOne@0"}
+	if _, err := planScenarios(cfg); err == nil {
+		t.Error("expected error for a zero worker count")
+	}
+}
+
+// concurrentWorkload is a registered scenario for TestPlanScenarioGlobs.
+type concurrentWorkload struct {
+	workload.Base
+}
+
+func (concurrentWorkload) Name() string                                           { return "TestRegisteredInsert" }
+func (concurrentWorkload) Concurrent() bool                                       { return true }
+func (concurrentWorkload) Op(ctx context.Context, env *workload.Env, i int) error { return nil }
+
+func init() {
+	workload.Register(concurrentWorkload{})
+}
+
+func TestPlanScenarioGlobs(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.scenarios = []string{"Find*ById*@4", "*RegisteredInsert@2/8", "Agg*@4"}
+	cfg.aggDecode = []bool{true}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]int{}
+	for _, p := range planned {
+		got[p.label()] = p.workers
+	}
+	for label, want := range map[string][]int{
+		"FindOneByIdWithoutDeserialization":            {4},
+		"FindOneByIdWithDeserialization":               {4},
+		"TestRegisteredInsert":                         {2, 8},
+		"AggMatchGroup[allowDiskUse=true,decode=true]": {4},
+		"AggSortSpill[allowDiskUse=true,decode=true]":  {1},
+	} {
+		if !slices.Equal(got[label], want) {
+			t.Errorf("%s: workers %v, want %v", label, got[label], want)
+		}
+	}
+	if len(planned) != 10 {
+		t.Errorf("planned %d scenarios, want 10", len(planned))
+	}
+
+	cfg.scenarios = []string{"NoSuch*"}
+	if _, err := planScenarios(cfg); err == nil {
+		t.Error("expected an error for a pattern that matches nothing")
+	}
+}
+
+func TestInsertManyBatchedVariants(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.scenarios = []string{"InsertManyBatched"}
+	cfg.batchSizes = []int{1, 300}
+	cfg.batchDocs = 1000
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 4 {
+		t.Fatalf("got %d variants, want 4", len(planned))
+	}
+
+	want := []struct {
+		label string
+		iters int
+	}{
+		{"InsertManyBatched[batchSize=1,ordered=true]", 1000},
+		{"InsertManyBatched[batchSize=1,ordered=false]", 1000},
+		{"InsertManyBatched[batchSize=300,ordered=true]", 4},
+		{"InsertManyBatched[batchSize=300,ordered=false]", 4},
+	}
+	for i, p := range planned {
+		if got := p.label(); got != want[i].label {
+			t.Errorf("variant %d label %q, want %q", i, got, want[i].label)
+		}
+		if got := p.iterations(cfg); got != want[i].iters {
+			t.Errorf("%s: %d iterations, want %d", p.label(), got, want[i].iters)
+		}
+	}
+}
+
+func TestIndexVariants(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.scenarios = []string{"IndexBuild", "IndexedInsert@1/4", "IndexedQuery"}
+	cfg.indexKinds = []string{"none", "multikey", "ttl"}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, p := range planned {
+		labels = append(labels, p.label())
+		if p.name == "IndexedInsert" && !slices.Equal(p.workers, []int{1, 4}) {
+			t.Errorf("%s: workers %v, want [1 4]", p.label(), p.workers)
+		}
+	}
+	want := []string{
+		"IndexBuild[index=multikey]", "IndexBuild[index=ttl]",
+		"IndexedInsert[index=none]", "IndexedInsert[index=multikey]", "IndexedInsert[index=ttl]",
+		"IndexedQuery[index=multikey]", "IndexedQuery[index=ttl]",
+	}
+	if !slices.Equal(labels, want) {
+		t.Errorf("got variants %q, want %q", labels, want)
+	}
+}
+
+func TestAggregationVariants(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.scenarios = []string{"AggLookup", "AggSortSpill@1"}
+	cfg.allowDiskUse = []bool{true, false}
+	cfg.aggDecode = []bool{false}
+	planned, err := planScenarios(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, p := range planned {
+		labels = append(labels, p.label())
+		if p.explain == nil || p.dataset.docs != aggregationDocs {
+			t.Errorf("%s: no explain hook or not on the aggregation collection", p.label())
+		}
+	}
+	want := []string{
+		"AggLookup[allowDiskUse=true,decode=false]", "AggLookup[allowDiskUse=false,decode=false]",
+		"AggSortSpill[allowDiskUse=true,decode=false]", "AggSortSpill[allowDiskUse=false,decode=false]",
+	}
+	if !slices.Equal(labels, want) {
+		t.Errorf("got variants %q, want %q", labels, want)
+	}
+	if !planned[0].dataset.categories || planned[2].setup == nil || planned[2].teardown == nil {
+		t.Error("AggLookup must seed categories and AggSortSpill must set and restore the sort limit")
+	}
+	if slices.Contains(planned[2].topologies, topologySharded) {
+		t.Error("AggSortSpill can't set the sort limit through mongos")
+	}
+	// The env never connects, so a teardown that tried to restore a limit
+	// its setup never saved would fail.
+	if err := planned[2].teardown(context.Background(), offlineEnv(t, cfg)); err != nil {
+		t.Errorf("teardown without setup: %v", err)
+	}
+}
+
+// TestScenariosDeclareDatasets guards against scenarios that only work after
+// another one has run: everything reading or changing existing documents
+// must ask for them.
+func TestScenariosDeclareDatasets(t *testing.T) {
+	for _, sc := range scenarios {
+		needsDocs := false
+		for _, prefix := range []string{"Update", "Delete", "Find", "CreateIndex", "DropCollection", "Secondary"} {
+			needsDocs = needsDocs || strings.HasPrefix(sc.name, prefix)
+		}
+		if needsDocs && sc.dataset.collection != collectionSeeded {
+			t.Errorf("%s doesn't declare a seeded collection", sc.name)
+		}
+		if strings.HasPrefix(sc.name, "GridFS") && !strings.Contains(sc.name, "Upload") && !sc.dataset.gridFSFile {
+			t.Errorf("%s doesn't declare the GridFS file", sc.name)
+		}
+	}
+}
+
+// shardedBatchWorkload declares the optional topology and count.
+type shardedBatchWorkload struct {
+	workload.Base
+}
+
+func (shardedBatchWorkload) Name() string                                           { return "TestShardedBatch" }
+func (shardedBatchWorkload) Topologies() []string                                   { return []string{topologySharded} }
+func (shardedBatchWorkload) Count() int                                             { return 3 }
+func (shardedBatchWorkload) Op(ctx context.Context, env *workload.Env, i int) error { return nil }
+
+func TestFromWorkloadOptions(t *testing.T) {
+	sc := fromWorkload(shardedBatchWorkload{})
+	if sc.runsOn(&serverInfo{Topology: topologyStandalone}) || !sc.runsOn(&serverInfo{Topology: topologySharded}) {
+		t.Errorf("topologies = %v, want sharded only", sc.topologies)
+	}
+	cfg := defaultRunConfig()
+	cfg.duration = time.Minute
+	if n, d := sc.limits(cfg); n != 3 || d != 0 {
+		t.Errorf("limits = %d, %v, want 3 iterations without a duration", n, d)
+	}
+	if n, _ := fromWorkload(concurrentWorkload{}).limits(cfg); n != math.MaxInt {
+		t.Errorf("uncounted workload runs %d iterations for -duration", n)
+	}
+}
+
+func TestScenarioColl(t *testing.T) {
+	cfg := defaultRunConfig()
+	env := offlineEnv(t, cfg)
+	for pattern, want := range map[string]string{
+		"UpdateOne":              "benchmarkMain.files",
+		"MajorityInsertOne":      "benchmarkMain.filesMajority",
+		"ScatterGatherFind":      "benchmarkMain.filesSharded",
+		"GridFSDownloadToStream": "benchmarkGridFS.fs.chunks",
+		"TestRegisteredInsert":   "benchmarkMain.files",
+	} {
+		found, err := lookupScenarios(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coll := found[0].coll(env)
+		if got := coll.Database().Name() + "." + coll.Name(); got != want {
+			t.Errorf("%s works on %s, want %s", pattern, got, want)
+		}
+	}
+	agg := aggMatchGroupVariants(cfg)[0]
+	if coll := agg.coll(env); coll.Name() != "filesAggregated" {
+		t.Errorf("AggMatchGroup works on %s, want filesAggregated", coll.Name())
+	}
+}
+
+func TestSecondaryFindExplainsOnSecondary(t *testing.T) {
+	i := slices.IndexFunc(scenarios, func(sc scenario) bool { return sc.name == "SecondaryFindOneById" })
+	if sc := scenarios[i]; sc.explainReadPref == nil || sc.explainReadPref.Mode() != readpref.SecondaryMode {
+		t.Errorf("SecondaryFindOneById explains with read preference %v, want secondary", sc.explainReadPref)
+	}
+}
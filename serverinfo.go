@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// serverInfo describes what a target actually runs, as opposed to what its
+// registry entry declares.
+type serverInfo struct {
+	Version       string `json:"version"`
+	StorageEngine string `json:"storageEngine,omitempty"`
+	FCV           string `json:"fcv,omitempty"`
+	Topology      string `json:"topology"`
+	// ReplicaSet, Primary and Members describe a replica set as the server
+	// we connected to sees it.
+	ReplicaSet string   `json:"replicaSet,omitempty"`
+	Primary    string   `json:"primary,omitempty"`
+	Members    []string `json:"members,omitempty"`
+	// Shards is the number of shards behind a mongos.
+	Shards int `json:"shards,omitempty"`
+}
+
+const (
+	versionCheckStrict = "strict"
+	versionCheckWarn   = "warn"
+	versionCheckOff    = "off"
+)
+
+func fetchServerInfo(ctx context.Context, client *mongo.Client) (*serverInfo, error) {
+	admin := client.Database("admin")
+	info := &serverInfo{}
+
+	var buildInfo struct {
+		Version string `bson:"version"`
+	}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo); err != nil {
+		return nil, fmt.Errorf("buildInfo: %w", err)
+	}
+	info.Version = buildInfo.Version
+
+	var hello struct {
+		SetName  string   `bson:"setName"`
+		Msg      string   `bson:"msg"`
+		Primary  string   `bson:"primary"`
+		Hosts    []string `bson:"hosts"`
+		Passives []string `bson:"passives"`
+		Arbiters []string `bson:"arbiters"`
+	}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return nil, fmt.Errorf("hello: %w", err)
+	}
+	switch {
+	case hello.Msg == "isdbgrid":
+		info.Topology = topologySharded
+		var shards struct {
+			Shards []bson.Raw `bson:"shards"`
+		}
+		if err := admin.RunCommand(ctx, bson.D{{Key: "listShards", Value: 1}}).Decode(&shards); err != nil {
+			log.Println("Error listShards:", err)
+		}
+		info.Shards = len(shards.Shards)
+	case hello.SetName != "":
+		info.Topology = topologyReplicaSet
+		info.ReplicaSet = hello.SetName
+		info.Primary = hello.Primary
+		info.Members = append(append(append(info.Members, hello.Hosts...), hello.Passives...), hello.Arbiters...)
+	default:
+		info.Topology = topologyStandalone
+	}
+
+	// serverStatus and getParameter need extra privileges on some deployments,
+	// so their fields are left empty rather than failing the run.
+	var status struct {
+		StorageEngine struct {
+			Name string `bson:"name"`
+		} `bson:"storageEngine"`
+	}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status); err != nil {
+		log.Println("Error serverStatus:", err)
+	}
+	info.StorageEngine = status.StorageEngine.Name
+
+	var param struct {
+		FCV struct {
+			Version string `bson:"version"`
+		} `bson:"featureCompatibilityVersion"`
+	}
+	cmd := bson.D{{Key: "getParameter", Value: 1}, {Key: "featureCompatibilityVersion", Value: 1}}
+	if err := admin.RunCommand(ctx, cmd).Decode(&param); err != nil {
+		log.Println("Error getParameter featureCompatibilityVersion:", err)
+	}
+	info.FCV = param.FCV.Version
+
+	return info, nil
+}
+
+// describeTopology is the topology with its details, e.g.
+// "replicaset rs0 (3 members, primary db1:27017)".
+func (info *serverInfo) describeTopology() string {
+	switch info.Topology {
+	case topologyReplicaSet:
+		return fmt.Sprintf("%s %s (%d members, primary %s)", info.Topology, info.ReplicaSet, len(info.Members), info.Primary)
+	case topologySharded:
+		return fmt.Sprintf("%s (%d shards)", info.Topology, info.Shards)
+	}
+	return info.Topology
+}
+
+// versionMatches reports whether version (e.g. "5.0.14") belongs to the
+// declared release series (e.g. "5.0" or "5.0.14").
+func versionMatches(declared, version string) bool {
+	return version == declared || strings.HasPrefix(version, declared+".")
+}
+
+// releaseSeries returns the major.minor part of a version string.
+func releaseSeries(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// checkServer compares a target's declaration against what the server reports.
+// It returns one message per mismatch.
+func checkServer(t Target, info *serverInfo) []string {
+	var problems []string
+	if t.Version != "" && !versionMatches(t.Version, info.Version) {
+		problems = append(problems, fmt.Sprintf("declared version %s but server runs %s", t.Version, info.Version))
+	}
+	if t.Topology != "" && t.Topology != info.Topology {
+		problems = append(problems, fmt.Sprintf("declared topology %s but server is %s", t.Topology, info.Topology))
+	}
+	if t.ReplicaSet != "" && info.ReplicaSet != "" && t.ReplicaSet != info.ReplicaSet {
+		problems = append(problems, fmt.Sprintf("declared replica set %s but server is in %s", t.ReplicaSet, info.ReplicaSet))
+	}
+	if info.FCV != "" && info.FCV != releaseSeries(info.Version) {
+		problems = append(problems, fmt.Sprintf("featureCompatibilityVersion is %s on a %s server", info.FCV, info.Version))
+	}
+	return problems
+}
+
+// verifyTarget fetches the server info and applies the version check mode.
+func verifyTarget(ctx context.Context, client *mongo.Client, t Target, mode string) (*serverInfo, error) {
+	info, err := fetchServerInfo(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Target %s: MongoDB %s, %s, FCV %s, %s", t.Name, info.Version, info.StorageEngine, info.FCV, info.describeTopology())
+
+	problems := checkServer(t, info)
+	if mode == versionCheckOff || len(problems) == 0 {
+		return info, nil
+	}
+	if mode == versionCheckStrict {
+		return nil, fmt.Errorf("target %s: %s", t.Name, strings.Join(problems, "; "))
+	}
+	for _, p := range problems {
+		log.Printf("Warning: target %s: %s", t.Name, p)
+	}
+	return info, nil
+}
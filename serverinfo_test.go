@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCheckServer(t *testing.T) {
+	tests := []struct {
+		declared string
+		topology string
+		info     serverInfo
+		problems int
+	}{
+		{"5.0", "", serverInfo{Version: "5.0.14", FCV: "5.0"}, 0},
+		{"5.0", "", serverInfo{Version: "5.0.14"}, 0},
+		{"8.0.4", "", serverInfo{Version: "8.0.4", FCV: "8.0"}, 0},
+		{"5.0", "", serverInfo{Version: "6.0.2", FCV: "6.0"}, 1},
+		{"5.0", "", serverInfo{Version: "5.01.0"}, 1},
+		{"6.0", "", serverInfo{Version: "6.0.2", FCV: "5.0"}, 1},
+		{"", "", serverInfo{Version: "7.0.1", FCV: "7.0"}, 0},
+		{"7.0", "replicaset", serverInfo{Version: "7.0.1", Topology: "replicaset"}, 0},
+		{"7.0", "sharded", serverInfo{Version: "7.0.1", Topology: "standalone"}, 1},
+	}
+	for _, tt := range tests {
+		got := checkServer(Target{Name: "t", Version: tt.declared, Topology: tt.topology}, &tt.info)
+		if len(got) != tt.problems {
+			t.Errorf("declared %q, server %+v: got problems %q, want %d", tt.declared, tt.info, got, tt.problems)
+		}
+	}
+}
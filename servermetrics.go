@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// serverMetrics is what the server did while a scenario's measured
+// iterations ran, from serverStatus, dbStats and $collStats sampled every
+// -metrics-interval. The sampling commands themselves show up in
+// opcounters.command and network.numRequests.
+type serverMetrics struct {
+	Samples int `json:"samples"`
+	// Deltas holds how much each counter grew over the run and Peaks the
+	// highest value each gauge was sampled at. Metrics the server doesn't
+	// report, like WiredTiger's on a mongos, are left out.
+	Deltas map[string]float64 `json:"deltas,omitempty"`
+	Peaks  map[string]float64 `json:"peaks,omitempty"`
+}
+
+type metricKind int
+
+const (
+	// counterMetric only grows while the server is up.
+	counterMetric metricKind = iota
+	// gaugeMetric is a level that goes up and down.
+	gaugeMetric
+)
+
+// statsSource is the command a metric is read from. serverStatus is
+// sampled throughout a run; dbStats and $collStats, which can be slow on
+// big deployments, only at its start and end.
+type statsSource int
+
+const (
+	fromServerStatus statsSource = iota
+	fromDBStats
+	fromCollStats
+)
+
+// serverMetric is one number the sampler records.
+type serverMetric struct {
+	name   string
+	kind   metricKind
+	source statsSource
+	// value reads the metric from its source's reply, false if the reply
+	// doesn't have it.
+	value func(doc bson.Raw) (float64, bool)
+}
+
+// serverMetricDefs are the metrics sampled, in the order of their CSV
+// columns. dbStats and $collStats cover the database and collection the
+// scenario works on; their sizes are counters, so a run records how much it
+// grew them.
+var serverMetricDefs = []serverMetric{
+	{name: "opcounters.insert", value: statAt("opcounters", "insert")},
+	{name: "opcounters.query", value: statAt("opcounters", "query")},
+	{name: "opcounters.update", value: statAt("opcounters", "update")},
+	{name: "opcounters.delete", value: statAt("opcounters", "delete")},
+	{name: "opcounters.getmore", value: statAt("opcounters", "getmore")},
+	{name: "opcounters.command", value: statAt("opcounters", "command")},
+	{name: "network.bytesIn", value: statAt("network", "bytesIn")},
+	{name: "network.bytesOut", value: statAt("network", "bytesOut")},
+	{name: "network.numRequests", value: statAt("network", "numRequests")},
+	{name: "pageFaults", value: statAt("extra_info", "page_faults")},
+	{name: "cache.bytesRead", value: statAt("wiredTiger", "cache", "bytes read into cache")},
+	{name: "cache.bytesWritten", value: statAt("wiredTiger", "cache", "bytes written from cache")},
+	{name: "cache.appEvictions", value: statAt("wiredTiger", "cache", "pages evicted by application threads")},
+	{name: "cache.bytes", kind: gaugeMetric, value: statAt("wiredTiger", "cache", "bytes currently in the cache")},
+	{name: "cache.dirtyBytes", kind: gaugeMetric, value: statAt("wiredTiger", "cache", "tracked dirty bytes in the cache")},
+	// Tickets moved from wiredTiger.concurrentTransactions to
+	// queues.execution in 7.0.
+	{name: "tickets.readOut", kind: gaugeMetric, value: firstStat(
+		statAt("queues", "execution", "read", "out"),
+		statAt("wiredTiger", "concurrentTransactions", "read", "out"),
+	)},
+	{name: "tickets.writeOut", kind: gaugeMetric, value: firstStat(
+		statAt("queues", "execution", "write", "out"),
+		statAt("wiredTiger", "concurrentTransactions", "write", "out"),
+	)},
+	{name: "globalLock.queued", kind: gaugeMetric, value: statAt("globalLock", "currentQueue", "total")},
+	{name: "locks.waits", value: lockStat("acquireWaitCount")},
+	{name: "locks.waitMicros", value: lockStat("timeAcquiringMicros")},
+	// WiredTiger's own locks and latches, as time application threads spent
+	// waiting on them.
+	{name: "wiredTiger.lockWaitMicros", value: sumStats([]string{"wiredTiger", "lock"}, func(key string) bool {
+		return strings.Contains(key, "application thread") && strings.HasSuffix(key, "(usecs)")
+	})},
+	{name: "db.objects", source: fromDBStats, value: statAt("objects")},
+	{name: "db.dataSize", source: fromDBStats, value: statAt("dataSize")},
+	{name: "db.storageSize", source: fromDBStats, value: statAt("storageSize")},
+	{name: "db.indexSize", source: fromDBStats, value: statAt("indexSize")},
+	{name: "coll.count", source: fromCollStats, value: statAt("storageStats", "count")},
+	{name: "coll.size", source: fromCollStats, value: statAt("storageStats", "size")},
+	{name: "coll.storageSize", source: fromCollStats, value: statAt("storageStats", "storageSize")},
+	{name: "coll.totalIndexSize", source: fromCollStats, value: statAt("storageStats", "totalIndexSize")},
+}
+
+// rawNumber is v as a float64, false if it isn't a number.
+func rawNumber(v bson.RawValue) (float64, bool) {
+	switch v.Type {
+	case bson.TypeInt32:
+		return float64(v.Int32()), true
+	case bson.TypeInt64:
+		return float64(v.Int64()), true
+	case bson.TypeDouble:
+		return v.Double(), true
+	}
+	return 0, false
+}
+
+func statAt(path ...string) func(bson.Raw) (float64, bool) {
+	return func(doc bson.Raw) (float64, bool) {
+		return rawNumber(doc.Lookup(path...))
+	}
+}
+
+// firstStat reads the metric from the first of values the reply has.
+func firstStat(values ...func(bson.Raw) (float64, bool)) func(bson.Raw) (float64, bool) {
+	return func(doc bson.Raw) (float64, bool) {
+		for _, value := range values {
+			if v, ok := value(doc); ok {
+				return v, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// sumStats adds up the numbers in the document at path whose keys match.
+func sumStats(path []string, match func(key string) bool) func(bson.Raw) (float64, bool) {
+	return func(doc bson.Raw) (float64, bool) {
+		sub, ok := doc.Lookup(path...).DocumentOK()
+		if !ok {
+			return 0, false
+		}
+		elems, _ := sub.Elements()
+		var sum float64
+		found := false
+		for _, e := range elems {
+			if !match(e.Key()) {
+				continue
+			}
+			if v, ok := rawNumber(e.Value()); ok {
+				sum += v
+				found = true
+			}
+		}
+		return sum, found
+	}
+}
+
+// lockStat sums one field of serverStatus's locks section over every
+// resource (Global, Database, Collection, ...) and lock mode.
+func lockStat(field string) func(bson.Raw) (float64, bool) {
+	return func(doc bson.Raw) (float64, bool) {
+		locks, ok := doc.Lookup("locks").DocumentOK()
+		if !ok {
+			return 0, false
+		}
+		resources, _ := locks.Elements()
+		var sum float64
+		found := false
+		for _, r := range resources {
+			resource, ok := r.Value().DocumentOK()
+			if !ok {
+				continue
+			}
+			if v, ok := sumStats([]string{field}, func(string) bool { return true })(resource); ok {
+				sum += v
+				found = true
+			}
+		}
+		return sum, found
+	}
+}
+
+// readMetrics runs the commands behind the metrics of the given sources
+// and reads them. A $collStats that fails, as it does on a collection that
+// doesn't exist yet, reads as no metrics.
+func readMetrics(ctx context.Context, client *mongo.Client, coll *mongo.Collection, sources ...statsSource) (map[string]float64, error) {
+	values := map[string]float64{}
+	read := func(source statsSource, doc bson.Raw, add bool) {
+		for _, m := range serverMetricDefs {
+			if m.source != source {
+				continue
+			}
+			if v, ok := m.value(doc); ok {
+				if add {
+					v += values[m.name]
+				}
+				values[m.name] = v
+			}
+		}
+	}
+	for _, source := range sources {
+		switch source {
+		case fromServerStatus:
+			doc, err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Raw()
+			if err != nil {
+				return nil, err
+			}
+			read(source, doc, false)
+		case fromDBStats:
+			doc, err := coll.Database().RunCommand(ctx, bson.D{{Key: "dbStats", Value: 1}}).Raw()
+			if err != nil {
+				return nil, err
+			}
+			read(source, doc, false)
+		case fromCollStats:
+			pipeline := mongo.Pipeline{{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}}}
+			cursor, err := coll.Aggregate(ctx, pipeline)
+			if err != nil {
+				continue
+			}
+			// A sharded collection has one document per shard.
+			for cursor.Next(ctx) {
+				read(source, cursor.Current, true)
+			}
+			cursor.Close(ctx)
+		}
+	}
+	return values, nil
+}
+
+// metricsSampler samples a target's metrics while a scenario runs.
+type metricsSampler struct {
+	client  *mongo.Client
+	coll    *mongo.Collection
+	first   map[string]float64
+	peaks   map[string]float64
+	samples int
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// sampleServerMetrics reads every metric once and then serverStatus every
+// interval until stop. Only the first read can fail the sampling; later
+// ones that fail are skipped.
+func sampleServerMetrics(ctx context.Context, client *mongo.Client, coll *mongo.Collection, interval time.Duration) (*metricsSampler, error) {
+	first, err := readMetrics(ctx, client, coll, fromServerStatus, fromDBStats, fromCollStats)
+	if err != nil {
+		return nil, err
+	}
+	s := &metricsSampler{client: client, coll: coll, first: first, peaks: map[string]float64{}, done: make(chan struct{})}
+	s.record(first)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			if values, err := readMetrics(ctx, client, coll, fromServerStatus); err == nil {
+				s.record(values)
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (s *metricsSampler) record(values map[string]float64) {
+	s.samples++
+	for _, m := range serverMetricDefs {
+		if v, ok := values[m.name]; ok && m.kind == gaugeMetric {
+			s.peaks[m.name] = math.Max(v, s.peaks[m.name])
+		}
+	}
+}
+
+// stop ends sampling, reads every metric a last time and summarises the
+// run.
+func (s *metricsSampler) stop(ctx context.Context) (*serverMetrics, error) {
+	close(s.done)
+	s.wg.Wait()
+	last, err := readMetrics(ctx, s.client, s.coll, fromServerStatus, fromDBStats, fromCollStats)
+	if err != nil {
+		return nil, err
+	}
+	s.record(last)
+	return &serverMetrics{Samples: s.samples, Deltas: counterDeltas(s.first, last), Peaks: s.peaks}, nil
+}
+
+// counterDeltas is how much each counter read in both first and last grew
+// between them.
+func counterDeltas(first, last map[string]float64) map[string]float64 {
+	deltas := map[string]float64{}
+	for _, m := range serverMetricDefs {
+		a, okA := first[m.name]
+		b, okB := last[m.name]
+		if m.kind == counterMetric && okA && okB {
+			deltas[m.name] = b - a
+		}
+	}
+	return deltas
+}
+
+// add sums up the metrics of two stretches of one run: counters grew by
+// both deltas and gauges peaked at the higher peak. Either may be nil.
+func (sm *serverMetrics) add(other *serverMetrics) *serverMetrics {
+	if sm == nil {
+		return other
+	}
+	if other == nil {
+		return sm
+	}
+	sum := &serverMetrics{Samples: sm.Samples + other.Samples, Deltas: map[string]float64{}, Peaks: map[string]float64{}}
+	for _, part := range []*serverMetrics{sm, other} {
+		for name, v := range part.Deltas {
+			sum.Deltas[name] += v
+		}
+		for name, v := range part.Peaks {
+			sum.Peaks[name] = math.Max(v, sum.Peaks[name])
+		}
+	}
+	return sum
+}
+
+// value is the metric's delta or peak, false if the run didn't record it.
+func (sm *serverMetrics) value(m serverMetric) (float64, bool) {
+	if sm == nil {
+		return 0, false
+	}
+	if m.kind == gaugeMetric {
+		v, ok := sm.Peaks[m.name]
+		return v, ok
+	}
+	v, ok := sm.Deltas[m.name]
+	return v, ok
+}
+
+// perOp is every metric the run recorded, with the counters divided by its
+// iterations so runs of different lengths compare.
+func (sm *serverMetrics) perOp(iterations int) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range serverMetricDefs {
+		if v, ok := sm.value(m); ok {
+			if m.kind == counterMetric {
+				v /= float64(iterations)
+			}
+			values[m.name] = v
+		}
+	}
+	return values
+}
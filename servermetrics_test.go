@@ -0,0 +1,104 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// readStatus reads the serverStatus metrics from doc.
+func readStatus(t *testing.T, doc bson.D) map[string]float64 {
+	t.Helper()
+	raw := mustRaw(t, doc)
+	values := map[string]float64{}
+	for _, m := range serverMetricDefs {
+		if v, ok := m.value(raw); ok && m.source == fromServerStatus {
+			values[m.name] = v
+		}
+	}
+	return values
+}
+
+func TestServerStatusMetrics(t *testing.T) {
+	locks := bson.D{
+		{Key: "Global", Value: bson.D{
+			{Key: "acquireWaitCount", Value: bson.D{{Key: "r", Value: int64(2)}, {Key: "w", Value: int64(1)}}},
+			{Key: "timeAcquiringMicros", Value: bson.D{{Key: "r", Value: int64(200)}, {Key: "w", Value: int64(50)}}},
+		}},
+		{Key: "Collection", Value: bson.D{
+			{Key: "acquireCount", Value: bson.D{{Key: "w", Value: int64(9)}}},
+			{Key: "timeAcquiringMicros", Value: bson.D{{Key: "w", Value: int64(5)}}},
+		}},
+	}
+	tickets := bson.D{
+		{Key: "read", Value: bson.D{{Key: "out", Value: int32(4)}, {Key: "available", Value: int32(124)}}},
+		{Key: "write", Value: bson.D{{Key: "out", Value: int32(1)}, {Key: "available", Value: int32(127)}}},
+	}
+
+	// 5.0 keeps the tickets in the wiredTiger section.
+	v50 := readStatus(t, bson.D{
+		{Key: "opcounters", Value: bson.D{{Key: "insert", Value: int64(10)}, {Key: "query", Value: int64(3)}}},
+		{Key: "network", Value: bson.D{{Key: "bytesIn", Value: int64(1000)}}},
+		{Key: "extra_info", Value: bson.D{{Key: "page_faults", Value: int32(7)}}},
+		{Key: "locks", Value: locks},
+		{Key: "wiredTiger", Value: bson.D{
+			{Key: "cache", Value: bson.D{{Key: "bytes currently in the cache", Value: 1.5e6}}},
+			{Key: "concurrentTransactions", Value: tickets},
+			{Key: "lock", Value: bson.D{
+				{Key: "dhandle lock application thread time waiting (usecs)", Value: int64(30)},
+				{Key: "txn global lock application thread time waiting (usecs)", Value: int64(12)},
+				{Key: "dhandle lock internal thread time waiting (usecs)", Value: int64(1000)},
+				{Key: "dhandle read lock acquisitions", Value: int64(1000)},
+			}},
+		}},
+	})
+	want := map[string]float64{
+		"opcounters.insert": 10, "opcounters.query": 3, "network.bytesIn": 1000, "pageFaults": 7,
+		"cache.bytes": 1.5e6, "tickets.readOut": 4, "tickets.writeOut": 1,
+		"locks.waits": 3, "locks.waitMicros": 255, "wiredTiger.lockWaitMicros": 42,
+	}
+	if !maps.Equal(v50, want) {
+		t.Errorf("5.0 metrics = %v, want %v", v50, want)
+	}
+
+	// 8.0 moved them to queues.execution; a mongos has no wiredTiger section.
+	v80 := readStatus(t, bson.D{{Key: "queues", Value: bson.D{{Key: "execution", Value: tickets}}}})
+	want = map[string]float64{"tickets.readOut": 4, "tickets.writeOut": 1}
+	if !maps.Equal(v80, want) {
+		t.Errorf("8.0 metrics = %v, want %v", v80, want)
+	}
+}
+
+func TestServerMetricsDeltasAndPerOp(t *testing.T) {
+	first := map[string]float64{"opcounters.insert": 100, "network.bytesIn": 5000, "cache.bytes": 1e6, "db.dataSize": 0}
+	last := map[string]float64{"opcounters.insert": 300, "network.bytesIn": 9000, "cache.bytes": 2e6, "coll.size": 64}
+	deltas := counterDeltas(first, last)
+	if want := map[string]float64{"opcounters.insert": 200, "network.bytesIn": 4000}; !maps.Equal(deltas, want) {
+		t.Errorf("deltas = %v, want %v", deltas, want)
+	}
+
+	sm := &serverMetrics{Deltas: deltas, Peaks: map[string]float64{"cache.bytes": 2e6}}
+	perOp := sm.perOp(200)
+	if want := map[string]float64{"opcounters.insert": 1, "network.bytesIn": 20, "cache.bytes": 2e6}; !maps.Equal(perOp, want) {
+		t.Errorf("per op = %v, want %v", perOp, want)
+	}
+}
+
+func TestServerMetricsAdd(t *testing.T) {
+	a := &serverMetrics{Samples: 3, Deltas: map[string]float64{"opcounters.insert": 200}, Peaks: map[string]float64{"cache.bytes": 2e6}}
+	b := &serverMetrics{Samples: 2, Deltas: map[string]float64{"opcounters.insert": 50, "network.bytesIn": 10}, Peaks: map[string]float64{"cache.bytes": 1e6}}
+	sum := a.add(b)
+	if sum.Samples != 5 {
+		t.Errorf("samples = %d, want 5", sum.Samples)
+	}
+	if want := map[string]float64{"opcounters.insert": 250, "network.bytesIn": 10}; !maps.Equal(sum.Deltas, want) {
+		t.Errorf("deltas = %v, want %v", sum.Deltas, want)
+	}
+	if want := map[string]float64{"cache.bytes": 2e6}; !maps.Equal(sum.Peaks, want) {
+		t.Errorf("peaks = %v, want %v", sum.Peaks, want)
+	}
+	if (*serverMetrics)(nil).add(b) != b {
+		t.Error("adding to no metrics isn't the metrics added")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	s := slices.Sorted(slices.Values(xs))
+	mid := len(s) / 2
+	if len(s)%2 == 1 {
+		return s[mid]
+	}
+	return (s[mid-1] + s[mid]) / 2
+}
+
+// stddev is the sample standard deviation.
+func stddev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	m := mean(xs)
+	var sq float64
+	for _, x := range xs {
+		sq += (x - m) * (x - m)
+	}
+	return math.Sqrt(sq / float64(len(xs)-1))
+}
+
+// cv is the coefficient of variation, the standard deviation relative to the
+// mean.
+func cv(xs []float64) float64 {
+	m := mean(xs)
+	if m == 0 {
+		return 0
+	}
+	return stddev(xs) / m
+}
+
+// tQuantile975 is the 0.975 quantile of Student's t distribution for 1 to 30
+// degrees of freedom.
+var tQuantile975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// confidenceInterval is the 95% confidence interval of the mean of xs, from
+// Student's t distribution. It is the mean itself for fewer than two
+// samples.
+func confidenceInterval(xs []float64) (lo, hi float64) {
+	m := mean(xs)
+	df := len(xs) - 1
+	if df < 1 {
+		return m, m
+	}
+	var t float64
+	if df <= len(tQuantile975) {
+		t = tQuantile975[df-1]
+	} else {
+		// Cornish-Fisher expansion around the normal quantile.
+		const z = 1.959964
+		t = z + (z*z*z+z)/(4*float64(df))
+	}
+	half := t * stddev(xs) / math.Sqrt(float64(len(xs)))
+	return m - half, m + half
+}
+
+func geomean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var logSum float64
+	for _, x := range xs {
+		if x <= 0 {
+			return 0
+		}
+		logSum += math.Log(x)
+	}
+	return math.Exp(logSum / float64(len(xs)))
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test, the
+// test benchstat uses to decide whether two sets of runs differ. Small
+// samples without ties use the exact distribution of U; otherwise the normal
+// approximation with tie correction.
+func mannWhitneyU(xs, ys []float64) float64 {
+	n1, n2 := len(xs), len(ys)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type obs struct {
+		v     float64
+		first bool
+	}
+	all := make([]obs, 0, n1+n2)
+	for _, x := range xs {
+		all = append(all, obs{x, true})
+	}
+	for _, y := range ys {
+		all = append(all, obs{y, false})
+	}
+	slices.SortFunc(all, func(a, b obs) int {
+		switch {
+		case a.v < b.v:
+			return -1
+		case a.v > b.v:
+			return 1
+		}
+		return 0
+	})
+
+	// Rank with ties given their average rank.
+	var rankSum1, tieTerm float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				rankSum1 += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u := rankSum1 - float64(n1*(n1+1))/2
+
+	if !ties && n1*n2 <= 2500 {
+		return exactUPValue(n1, n2, u)
+	}
+
+	n := float64(n1 + n2)
+	mu := float64(n1*n2) / 2
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	// Continuity correction towards the mean.
+	z := (math.Abs(u-mu) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// exactUPValue computes the two-sided p-value of u from the exact null
+// distribution of U for sample sizes n1 and n2.
+func exactUPValue(n1, n2 int, u float64) float64 {
+	maxU := n1 * n2
+	// prev[i][v] and cur[i][v] count the orderings of i first-sample and j
+	// second-sample values whose statistic is v, built up one second-sample
+	// value at a time.
+	prev := make([][]float64, n1+1)
+	for i := range prev {
+		prev[i] = make([]float64, maxU+1)
+		prev[i][0] = 1
+	}
+	for j := 1; j <= n2; j++ {
+		cur := make([][]float64, n1+1)
+		cur[0] = make([]float64, maxU+1)
+		cur[0][0] = 1
+		for i := 1; i <= n1; i++ {
+			cur[i] = make([]float64, maxU+1)
+			for v := 0; v <= maxU; v++ {
+				// Largest value comes from the second sample: U unchanged.
+				c := prev[i][v]
+				// Largest value comes from the first sample: it beats all j.
+				if v >= j {
+					c += cur[i-1][v-j]
+				}
+				cur[i][v] = c
+			}
+		}
+		prev = cur
+	}
+	dist := prev[n1]
+
+	var total, lower, upper float64
+	for v, c := range dist {
+		total += c
+		if float64(v) <= u {
+			lower += c
+		}
+		if float64(v) >= u {
+			upper += c
+		}
+	}
+	return math.Min(1, 2*math.Min(lower, upper)/total)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// fBenchmarkTargets runs the suite against every registered target, see
+// loadTargets for how targets are configured.
+func fBenchmarkTargets(b *testing.B) {
+	targets, err := loadTargets("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, t := range targets {
+		b.Run(t.Name, func(b *testing.B) {
+			benchmarkTarget(b, t)
+		})
+	}
+}
+
+func benchmarkTarget(b *testing.B, t Target) {
+	t, stopMongod, err := launchTarget(context.TODO(), t)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stopMongod()
+
+	client, err := connect(t)
+	if err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		if err := client.Disconnect(context.TODO()); err != nil {
+			panic(err)
+		}
+	}()
+	println("Connected to", t.Name)
+
+	info, err := verifyTarget(context.TODO(), client, t, versionCheckStrict)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	env := newRunEnv(client, defaultRunConfig())
+	planned, err := planScenarios(env.cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, sc := range planned {
+		b.Run(sc.label(), func(b *testing.B) {
+			if !sc.runsOn(info) {
+				b.Skipf("needs a %s deployment", strings.Join(sc.topologies, " or "))
+			}
+			benchScenario(b, env, sc.scenario)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target is a MongoDB deployment to benchmark.
+type Target struct {
+	Name string `json:"name" yaml:"name"`
+	// URI is the connection string. It may be left out when Hosts is given.
+	URI string `json:"uri,omitempty" yaml:"uri,omitempty"`
+	// Version is the server version the target is expected to run, e.g. "5.0".
+	Version string   `json:"version" yaml:"version"`
+	Tags    []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Topology is what the deployment is expected to be: standalone,
+	// replicaset or sharded. Empty means whatever the server reports.
+	Topology string `json:"topology,omitempty" yaml:"topology,omitempty"`
+	// Hosts seeds the connection instead of URI: the replica set members or,
+	// for a sharded cluster, the mongos routers.
+	Hosts []string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	// ReplicaSet is the replica set name used with Hosts.
+	ReplicaSet string `json:"replicaSet,omitempty" yaml:"replicaSet,omitempty"`
+	// Binary is a mongod executable to launch for the run instead of
+	// connecting to a running deployment, see startMongod. A replicaset
+	// topology or a ReplicaSet name starts it as a single-member replica set.
+	Binary string `json:"binary,omitempty" yaml:"binary,omitempty"`
+	// MongodArgs are extra command line arguments for Binary.
+	MongodArgs []string `json:"mongodArgs,omitempty" yaml:"mongodArgs,omitempty"`
+}
+
+const (
+	topologyStandalone = "standalone"
+	topologyReplicaSet = "replicaset"
+	topologySharded    = "sharded"
+)
+
+// connectionURI returns URI, or one built from Hosts and ReplicaSet.
+func (t Target) connectionURI() string {
+	if t.URI != "" || len(t.Hosts) == 0 {
+		return t.URI
+	}
+	uri := "mongodb://" + strings.Join(t.Hosts, ",") + "/"
+	if t.ReplicaSet != "" {
+		uri += "?replicaSet=" + url.QueryEscape(t.ReplicaSet)
+	}
+	return uri
+}
+
+// defaultTargets are the local mongod instances the suites were written against.
+var defaultTargets = []Target{
+	{Name: "mongo50", URI: "mongodb://localhost:27015", Version: "5.0"},
+	{Name: "mongo60", URI: "mongodb://localhost:27016", Version: "6.0"},
+	{Name: "mongo70", URI: "mongodb://localhost:27017", Version: "7.0"},
+	{Name: "mongo80", URI: "mongodb://localhost:27018", Version: "8.0"},
+}
+
+const (
+	targetsFileEnv   = "SPEEDTEST_TARGETS_FILE"
+	targetEnvPrefix  = "SPEEDTEST_TARGET_"
+	targetEnvURI     = "_URI"
+	targetEnvVersion = "_VERSION"
+	targetEnvTags    = "_TAGS"
+	targetEnvTopo    = "_TOPOLOGY"
+	targetEnvHosts   = "_HOSTS"
+	targetEnvSet     = "_REPLICA_SET"
+	targetEnvBinary  = "_BINARY"
+)
+
+// loadTargets builds the target registry. Targets come from path, or the file
+// named by SPEEDTEST_TARGETS_FILE, or defaultTargets; SPEEDTEST_TARGET_<NAME>_URI,
+// _VERSION, _TAGS, _TOPOLOGY, _HOSTS, _REPLICA_SET and _BINARY variables then
+// add or override individual targets.
+func loadTargets(path string) ([]Target, error) {
+	if path == "" {
+		path = os.Getenv(targetsFileEnv)
+	}
+
+	targets := slices.Clone(defaultTargets)
+	if path != "" {
+		var err error
+		if targets, err = readTargetsFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	targets = mergeTargets(targets, targetsFromEnv(os.Environ()))
+	for _, t := range targets {
+		if t.Name == "" || (t.connectionURI() == "" && t.Binary == "") {
+			return nil, fmt.Errorf("target %q: name and uri, hosts or binary are required", t.Name)
+		}
+		switch t.Topology {
+		case "", topologyStandalone, topologyReplicaSet, topologySharded:
+		default:
+			return nil, fmt.Errorf("target %q: unknown topology %q", t.Name, t.Topology)
+		}
+	}
+	return targets, nil
+}
+
+// readTargetsFile reads a YAML or JSON file holding either a list of targets
+// or an object with a "targets" list.
+func readTargetsFile(path string) ([]Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Targets []Target `yaml:"targets"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err == nil && doc.Targets != nil {
+		return doc.Targets, nil
+	}
+
+	var list []Target
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+func targetsFromEnv(environ []string) []Target {
+	byName := map[string]*Target{}
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(key, targetEnvPrefix) {
+			continue
+		}
+		key = strings.TrimPrefix(key, targetEnvPrefix)
+
+		var field string
+		for _, suffix := range []string{targetEnvURI, targetEnvVersion, targetEnvTags, targetEnvTopo, targetEnvHosts, targetEnvSet, targetEnvBinary} {
+			if strings.HasSuffix(key, suffix) {
+				field = suffix
+				key = strings.TrimSuffix(key, suffix)
+				break
+			}
+		}
+		if field == "" || key == "" {
+			continue
+		}
+
+		name := strings.ToLower(key)
+		t, ok := byName[name]
+		if !ok {
+			t = &Target{Name: name}
+			byName[name] = t
+		}
+		switch field {
+		case targetEnvURI:
+			t.URI = value
+		case targetEnvVersion:
+			t.Version = value
+		case targetEnvTags:
+			t.Tags = splitList(value)
+		case targetEnvTopo:
+			t.Topology = value
+		case targetEnvHosts:
+			t.Hosts = splitList(value)
+		case targetEnvSet:
+			t.ReplicaSet = value
+		case targetEnvBinary:
+			t.Binary = value
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	targets := make([]Target, 0, len(names))
+	for _, name := range names {
+		targets = append(targets, *byName[name])
+	}
+	return targets
+}
+
+// mergeTargets overlays the set fields of overrides onto base by name,
+// appending targets base doesn't have.
+func mergeTargets(base, overrides []Target) []Target {
+	for _, o := range overrides {
+		i := slices.IndexFunc(base, func(t Target) bool { return t.Name == o.Name })
+		if i < 0 {
+			base = append(base, o)
+			continue
+		}
+		if o.URI != "" {
+			base[i].URI = o.URI
+		}
+		if o.Version != "" {
+			base[i].Version = o.Version
+		}
+		if o.Tags != nil {
+			base[i].Tags = o.Tags
+		}
+		if o.Topology != "" {
+			base[i].Topology = o.Topology
+		}
+		if o.Hosts != nil {
+			base[i].Hosts = o.Hosts
+		}
+		if o.ReplicaSet != "" {
+			base[i].ReplicaSet = o.ReplicaSet
+		}
+		if o.Binary != "" {
+			base[i].Binary = o.Binary
+		}
+		if o.MongodArgs != nil {
+			base[i].MongodArgs = o.MongodArgs
+		}
+	}
+	return base
+}
+
+// selectTargets picks targets by name or by "tag:<tag>". An empty selection
+// means every registered target.
+func selectTargets(all []Target, selectors []string) ([]Target, error) {
+	if len(selectors) == 0 {
+		return all, nil
+	}
+
+	var selected []Target
+	for _, sel := range selectors {
+		matched := false
+		for _, t := range all {
+			ok := t.Name == sel
+			if tag, isTag := strings.CutPrefix(sel, "tag:"); isTag {
+				ok = slices.Contains(t.Tags, tag)
+			}
+			if ok && !slices.ContainsFunc(selected, func(s Target) bool { return s.Name == t.Name }) {
+				selected = append(selected, t)
+			}
+			matched = matched || ok
+		}
+		if !matched {
+			return nil, fmt.Errorf("no target matches %q", sel)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTargetsFromEnv(t *testing.T) {
+	targets := targetsFromEnv([]string{
+		"SPEEDTEST_TARGET_MONGO82_URI=mongodb://localhost:27019",
+		"SPEEDTEST_TARGET_MONGO82_VERSION=8.2",
+		"SPEEDTEST_TARGET_MONGO82_TAGS=replset, rc",
+		"SPEEDTEST_TARGETS_FILE=ignored.yaml",
+		"HOME=/root",
+	})
+	if len(targets) != 1 {
+		t.Fatalf("got %d targets, want 1: %+v", len(targets), targets)
+	}
+	got := targets[0]
+	if got.Name != "mongo82" || got.URI != "mongodb://localhost:27019" || got.Version != "8.2" {
+		t.Errorf("unexpected target %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "replset" || got.Tags[1] != "rc" {
+		t.Errorf("unexpected tags %q", got.Tags)
+	}
+}
+
+func TestMergeTargetsOverridesByName(t *testing.T) {
+	merged := mergeTargets(
+		[]Target{{Name: "mongo50", URI: "mongodb://a", Version: "5.0"}},
+		[]Target{{Name: "mongo50", URI: "mongodb://b"}, {Name: "mongo82", URI: "mongodb://c"}},
+	)
+	if len(merged) != 2 {
+		t.Fatalf("got %d targets, want 2", len(merged))
+	}
+	if merged[0].URI != "mongodb://b" || merged[0].Version != "5.0" {
+		t.Errorf("override not applied: %+v", merged[0])
+	}
+}
+
+func TestConnectionURI(t *testing.T) {
+	tests := []struct {
+		target Target
+		want   string
+	}{
+		{Target{URI: "mongodb://a:27017"}, "mongodb://a:27017"},
+		{Target{URI: "mongodb://a:27017", Hosts: []string{"b:27017"}}, "mongodb://a:27017"},
+		{Target{Hosts: []string{"a:27017", "b:27017"}, ReplicaSet: "rs0"}, "mongodb://a:27017,b:27017/?replicaSet=rs0"},
+		{Target{Hosts: []string{"mongos1:27017", "mongos2:27017"}}, "mongodb://mongos1:27017,mongos2:27017/"},
+	}
+	for _, tt := range tests {
+		if got := tt.target.connectionURI(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestReadTargetsFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"list.json": `[{"name": "a", "uri": "mongodb://a", "version": "7.0"}]`,
+		"obj.yaml":  "targets:\n  - name: a\n    uri: mongodb://a\n    version: \"7.0\"\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		targets, err := readTargetsFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(targets) != 1 || targets[0].Version != "7.0" {
+			t.Errorf("%s: unexpected targets %+v", name, targets)
+		}
+	}
+}
+
+func TestSelectTargetsByTag(t *testing.T) {
+	all := []Target{
+		{Name: "a", Tags: []string{"replset"}},
+		{Name: "b"},
+		{Name: "c", Tags: []string{"replset"}},
+	}
+	got, err := selectTargets(all, []string{"tag:replset", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "a" || got[1].Name != "c" {
+		t.Errorf("unexpected selection %+v", got)
+	}
+	if _, err := selectTargets(all, []string{"missing"}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// steadyWindows is how many consecutive throughput windows must agree
+// within -steady-cv for a run to count as steady.
+const steadyWindows = 5
+
+// warmupSummary describes the untimed warmup before a scenario's measured
+// iterations.
+type warmupSummary struct {
+	Ops        int   `json:"ops"`
+	DurationNs int64 `json:"durationNs"`
+	// CV is the coefficient of variation of the last steadyWindows
+	// throughput windows, or 0 if the warmup ended before there were that
+	// many.
+	CV     float64 `json:"cv"`
+	Steady bool    `json:"steady"`
+}
+
+// throughputSampler records the ops/s of each window while a phase runs.
+// Workers count finished ops in completed; onSample, if set, sees the rates
+// so far after every window.
+type throughputSampler struct {
+	completed atomic.Int64
+	rates     []float64
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func sampleThroughput(window time.Duration, onSample func(rates []float64)) *throughputSampler {
+	s := &throughputSampler{done: make(chan struct{})}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(window)
+		defer ticker.Stop()
+		last := int64(0)
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			n := s.completed.Load()
+			s.rates = append(s.rates, float64(n-last)/window.Seconds())
+			last = n
+			if onSample != nil {
+				onSample(s.rates)
+			}
+		}
+	}()
+	return s
+}
+
+// stop ends sampling and returns the rate of every complete window.
+func (s *throughputSampler) stop() []float64 {
+	close(s.done)
+	s.wg.Wait()
+	return s.rates
+}
+
+// windowCV is the coefficient of variation of the last k rates, false if
+// there are fewer.
+func windowCV(rates []float64, k int) (float64, bool) {
+	if len(rates) < k {
+		return 0, false
+	}
+	return cv(rates[len(rates)-k:]), true
+}
+
+// warmUp runs the scenario's op untimed from iteration first until
+// throughput has been steady for steadyWindows windows or -warmup has
+// passed, whichever comes first.
+func warmUp(ctx context.Context, envs []*runEnv, sc scenario, first int) *warmupSummary {
+	cfg := envs[0].cfg
+	summary := &warmupSummary{}
+	var settled atomic.Bool
+	sampler := sampleThroughput(cfg.steadyWindow, func(rates []float64) {
+		if c, ok := windowCV(rates, steadyWindows); ok {
+			summary.CV = c
+			if c <= cfg.steadyCV {
+				settled.Store(true)
+			}
+		}
+	})
+	stats, _, elapsed := runPhase(ctx, envs, sc, phase{
+		first:     first,
+		n:         math.MaxInt,
+		duration:  cfg.warmup,
+		interval:  cfg.interval(),
+		stop:      &settled,
+		completed: &sampler.completed,
+	})
+	sampler.stop()
+
+	summary.Steady = settled.Load()
+	summary.DurationNs = elapsed.Nanoseconds()
+	for _, st := range stats {
+		summary.Ops += st.ops
+	}
+	return summary
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWindowCV(t *testing.T) {
+	if _, ok := windowCV([]float64{100, 100}, steadyWindows); ok {
+		t.Error("expected no CV from fewer windows than steadyWindows")
+	}
+	rates := []float64{10, 500, 1000, 1000, 1000, 1000, 1000}
+	if c, ok := windowCV(rates, steadyWindows); !ok || c > 0.3 {
+		t.Errorf("CV of the last windows %v, %v", c, ok)
+	}
+	if c := cv(rates); c < 0.3 {
+		t.Errorf("CV of all windows %v, want the ramp to show", c)
+	}
+}
+
+func TestWarmUpEndsOnceSteady(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.warmup = 5 * time.Second
+	cfg.steadyWindow = 20 * time.Millisecond
+	cfg.steadyCV = 0.25
+	sc := scenario{name: "Steady", op: func(ctx context.Context, env *runEnv, i int) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	}}
+
+	w := warmUp(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	if !w.Steady || time.Duration(w.DurationNs) > time.Second || w.Ops == 0 {
+		t.Errorf("warmup %+v, want it to settle well before -warmup", w)
+	}
+}
+
+func TestRunScenarioFlagsUnsteadyThroughput(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.warmup = 200 * time.Millisecond
+	cfg.duration = 200 * time.Millisecond
+	cfg.steadyWindow = 20 * time.Millisecond
+	cfg.steadyCV = 0.1
+	// Throughput drops tenfold for three windows out of every six.
+	sc := scenario{name: "Flapping", op: func(ctx context.Context, env *runEnv, i int) error {
+		if time.Now().UnixMilli()/60%2 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}}
+
+	res, _, _ := runScenario(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	if res.Warmup == nil || res.Warmup.Steady || !res.Unsteady || res.ThroughputCV <= cfg.steadyCV {
+		t.Errorf("result %+v, warmup %+v: want both phases unsteady", res, res.Warmup)
+	}
+}
+
+func TestRunScenarioNextSkipsWarmupIterations(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.warmup = 50 * time.Millisecond
+	cfg.iterations = 20
+	var seen []int
+	sc := scenario{name: "Counting", op: func(ctx context.Context, env *runEnv, i int) error {
+		seen = append(seen, i)
+		time.Sleep(time.Millisecond)
+		return nil
+	}}
+
+	res, _, next := runScenario(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 100)
+	warm := res.Warmup.Ops
+	if warm == 0 || res.Iterations != 20 || next != 100+warm+20 {
+		t.Fatalf("warmup %d ops, measured %d, next %d: want next after both", warm, res.Iterations, next)
+	}
+	if seen[warm] != 100+warm || seen[len(seen)-1] != next-1 {
+		t.Errorf("measured iterations %d..%d, want %d..%d", seen[warm], seen[len(seen)-1], 100+warm, next-1)
+	}
+}
+
+func TestRunScenarioPreparesAgainAfterWarmup(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.warmup = 20 * time.Millisecond
+	cfg.iterations = 5
+	var calls []string
+	sc := scenario{
+		name: "Stateful",
+		setup: func(ctx context.Context, env *runEnv) error {
+			calls = append(calls, "setup")
+			return nil
+		},
+		op: func(ctx context.Context, env *runEnv, i int) error {
+			if len(calls) == 0 || calls[len(calls)-1] != "op" {
+				calls = append(calls, "op")
+			}
+			return nil
+		},
+		teardown: func(ctx context.Context, env *runEnv) error {
+			calls = append(calls, "teardown")
+			return nil
+		},
+	}
+
+	runScenario(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	if want := []string{"setup", "op", "teardown", "setup", "op", "teardown"}; !slices.Equal(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+
+	calls = nil
+	sc.setup = func(ctx context.Context, env *runEnv) error {
+		calls = append(calls, "setup")
+		return errors.New("half done")
+	}
+	res, _, _ := runScenario(context.Background(), []*runEnv{offlineEnv(t, cfg)}, sc, 0)
+	if want := []string{"setup", "teardown"}; !slices.Equal(calls, want) || res.Errors != 1 {
+		t.Errorf("failed setup: calls %q and %d errors, want %q and 1", calls, res.Errors, want)
+	}
+}
+
+func TestRunScenarioConcurrentWarmupLeavesNoGaps(t *testing.T) {
+	cfg := defaultRunConfig()
+	cfg.warmup = 5 * time.Second
+	cfg.steadyWindow = 10 * time.Millisecond
+	cfg.steadyCV = 0.5
+	cfg.iterations = 200
+	var mu sync.Mutex
+	ran := map[int]int{}
+	sc := scenario{name: "Inserting", concurrent: true, op: func(ctx context.Context, env *runEnv, i int) error {
+		mu.Lock()
+		ran[i]++
+		mu.Unlock()
+		time.Sleep(100 * time.Microsecond)
+		return nil
+	}}
+
+	env := offlineEnv(t, cfg)
+	_, _, next := runScenario(context.Background(), []*runEnv{env, env, env, env}, sc, 0)
+	// The warmup ends on the steady signal while workers are mid-claim; the
+	// measurement must still start after every id the warmup used.
+	if len(ran) != next {
+		t.Errorf("ran %d distinct iterations, next is %d", len(ran), next)
+	}
+	for i, n := range ran {
+		if n > 1 || i >= next {
+			t.Errorf("iteration %d ran %d times, next is %d", i, n, next)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package workload_test
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+
+	"mongoVersionSpeedTest/workload"
+)
+
+// countByCategory counts the documents of one category per iteration.
+type countByCategory struct {
+	workload.Base
+}
+
+func (countByCategory) Name() string     { return "TeamCountByCategory" }
+func (countByCategory) Concurrent() bool { return true }
+
+func (countByCategory) Op(ctx context.Context, env *workload.Env, i int) error {
+	categories := []string{"audio", "image", "video"}
+	_, err := env.Collection.CountDocuments(ctx, bson.M{"category": categories[i%len(categories)]})
+	return err
+}
+
+// A team's package registers its scenarios from init; `run -scenarios
+// 'Team*'` then selects them.
+func ExampleRegister() {
+	workload.Register(countByCategory{})
+}
@@ -0,0 +1,143 @@
+// Package workload lets other packages add scenarios to the benchmark.
+//
+// A package registers its scenarios from an init function:
+//
+//	func init() {
+//		workload.Register(countByCategory{})
+//	}
+//
+// and is linked into the binary with a blank import next to main, e.g. in a
+// workloads.go of its own:
+//
+//	import _ "example.com/team/benchworkloads"
+//
+// Registered scenarios are then listed by the help command and selected
+// with -scenarios like the built-in ones, by name or by glob.
+package workload
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Env is what a scenario operates on for one target. The database and
+// collection carry the write concern, read concern and read preference of
+// the run's current concern setting.
+type Env struct {
+	Client     *mongo.Client
+	Database   *mongo.Database
+	Collection *mongo.Collection
+	// Iterations is -n, how many times Op is called unless the run says
+	// otherwise.
+	Iterations int
+	// Seed is the run's document generator seed, for scenarios that generate
+	// their own data reproducibly.
+	Seed uint64
+}
+
+// Scenario is a named benchmark operation. Setup runs before the timed
+// iterations and Teardown after them; one call to Op is one iteration, with
+// i counting from 0. Params tell apart several scenarios registered under
+// the same name, e.g. {"batchSize": "100"}, and may be nil.
+type Scenario interface {
+	Name() string
+	Params() map[string]string
+	Setup(ctx context.Context, env *Env) error
+	Op(ctx context.Context, env *Env, i int) error
+	Teardown(ctx context.Context, env *Env) error
+}
+
+// Concurrent is implemented by scenarios whose Op may be called from several
+// workers at once, each with a distinct i. Others always run on one worker.
+type Concurrent interface {
+	Concurrent() bool
+}
+
+// Topologies is implemented by scenarios that only mean something on some
+// deployments, e.g. []string{"replicaset", "sharded"}; "standalone" is the
+// third. Targets of other topologies skip them.
+type Topologies interface {
+	Topologies() []string
+}
+
+// Counted is implemented by batch scenarios that always run Count
+// iterations, whatever the run's iteration count or duration.
+type Counted interface {
+	Count() int
+}
+
+// Namespace is implemented by scenarios that work on a collection other
+// than env.Collection, so the database and collection statistics sampled
+// during their runs describe the right one.
+type Namespace interface {
+	Namespace(env *Env) *mongo.Collection
+}
+
+// Base gives a scenario no params and no-op Setup and Teardown. Embed it and
+// implement Name and Op.
+type Base struct{}
+
+func (Base) Params() map[string]string                    { return nil }
+func (Base) Setup(ctx context.Context, env *Env) error    { return nil }
+func (Base) Teardown(ctx context.Context, env *Env) error { return nil }
+
+var (
+	mu         sync.Mutex
+	registered []Scenario
+)
+
+// Register adds s to the scenarios the benchmark can run. It panics if s has
+// no name or one with the same name and params is already registered, as
+// that is a programming error in the registering package.
+func Register(s Scenario) {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.Name() == "" {
+		panic("workload: Register of a scenario without a name")
+	}
+	for _, r := range registered {
+		if strings.EqualFold(r.Name(), s.Name()) && maps.Equal(r.Params(), s.Params()) {
+			panic(fmt.Sprintf("workload: scenario %s registered twice", s.Name()))
+		}
+	}
+	registered = append(registered, s)
+}
+
+// All returns the registered scenarios in registration order.
+func All() []Scenario {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Scenario(nil), registered...)
+}
+
+// Match returns the registered scenarios whose name matches pattern, a
+// path.Match glob compared case-insensitively. A pattern without wildcards
+// matches the name exactly.
+func Match(pattern string) ([]Scenario, error) {
+	var found []Scenario
+	for _, s := range All() {
+		ok, err := MatchName(pattern, s.Name())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+// MatchName reports whether a scenario name matches a -scenarios pattern.
+func MatchName(pattern, name string) (bool, error) {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	if err != nil {
+		return false, fmt.Errorf("bad scenario pattern %q: %w", pattern, err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,58 @@
+package workload
+
+import (
+	"context"
+	"testing"
+)
+
+type testScenario struct {
+	Base
+	name   string
+	params map[string]string
+}
+
+func (s testScenario) Name() string                                  { return s.name }
+func (s testScenario) Params() map[string]string                     { return s.params }
+func (s testScenario) Op(ctx context.Context, env *Env, i int) error { return nil }
+
+func TestRegisterAndMatch(t *testing.T) {
+	defer func(saved []Scenario) { registered = saved }(registered)
+	registered = nil
+
+	Register(testScenario{name: "TeamCountDocuments"})
+	Register(testScenario{name: "TeamBulkWrite", params: map[string]string{"ordered": "true"}})
+	Register(testScenario{name: "TeamBulkWrite", params: map[string]string{"ordered": "false"}})
+	Register(testScenario{name: "OtherScan"})
+
+	for pattern, want := range map[string]int{
+		"TeamBulkWrite":      2,
+		"teamcountdocuments": 1,
+		"Team*":              3,
+		"*Scan":              1,
+		"Missing":            0,
+	} {
+		found, err := Match(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != want {
+			t.Errorf("Match(%q) found %d scenarios, want %d", pattern, len(found), want)
+		}
+	}
+	if _, err := Match("Team["); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	defer func(saved []Scenario) { registered = saved }(registered)
+	registered = nil
+
+	Register(testScenario{name: "TeamBulkWrite", params: map[string]string{"ordered": "true"}})
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic registering the same scenario twice")
+		}
+	}()
+	Register(testScenario{name: "teambulkwrite", params: map[string]string{"ordered": "true"}})
+}